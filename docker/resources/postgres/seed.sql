@@ -0,0 +1,53 @@
+-- Sample data for local development and the integration tests. The schema
+-- itself is created by `bss migrate up`, which must run first. Safe to load
+-- more than once.
+
+-- Insert sample plan
+INSERT INTO plans (id, code, name, price_cents, currency, duration_days, data_mb, active)
+VALUES (
+    '11111111-1111-1111-1111-111111111111',
+    'BASIC-MONTHLY',
+    'Basic Monthly Plan',
+    999,
+    'USD',
+    30,
+    5120,
+    true
+)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO plan_versions (plan_id, version, code, name, price_cents, currency, duration_days, data_mb)
+SELECT id, version, code, name, price_cents, currency, duration_days, data_mb FROM plans
+WHERE id = '11111111-1111-1111-1111-111111111111'
+ON CONFLICT DO NOTHING;
+
+-- Insert sample subscription
+INSERT INTO subscriptions (id, customer_id, plan_id, start_date, end_date, status, auto_renew)
+VALUES (
+    '22222222-2222-2222-2222-222222222222',
+    '00000000-0000-0000-0000-000000000000',
+    '11111111-1111-1111-1111-111111111111',
+    NOW() - INTERVAL '1 days',
+    NOW() + INTERVAL '30 days',
+    'ACTIVE',
+    true
+),
+(
+    '33333333-3333-3333-3333-333333333333',
+    '00000000-0000-0000-0000-000000000000',
+    '11111111-1111-1111-1111-111111111111',
+    NOW() - INTERVAL '30 days',
+    NOW() - INTERVAL '2 days',
+    'EXPIRED',
+    true
+),
+(
+    '44444444-4444-4444-4444-444444444444',
+    '00000000-0000-0000-0000-000000000001',
+    '11111111-1111-1111-1111-111111111111',
+    NOW() - INTERVAL '32 days',
+    NOW() - INTERVAL '4 days',
+    'EXPIRED',
+    true
+)
+ON CONFLICT DO NOTHING;
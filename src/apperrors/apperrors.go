@@ -0,0 +1,127 @@
+// Package apperrors defines the errors the service reports to its clients.
+// Each error carries a stable Code that callers can switch on and that the
+// server package maps to an HTTP status.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Code string
+
+const (
+	CodeValidationFailed           Code = "VALIDATION_FAILED"
+	CodePlanNotFound               Code = "PLAN_NOT_FOUND"
+	CodePlanAlreadyExists          Code = "PLAN_ALREADY_EXISTS"
+	CodePlanModified               Code = "PLAN_MODIFIED"
+	CodeSubscriptionNotFound       Code = "SUBSCRIPTION_NOT_FOUND"
+	CodeSubscriptionAlreadyExists  Code = "SUBSCRIPTION_ALREADY_EXISTS"
+	CodeSubscriptionAlreadyRenewed Code = "SUBSCRIPTION_ALREADY_RENEWED"
+	CodeIdempotencyKeyReused       Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInProgress   Code = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	CodeRequestTooLarge            Code = "REQUEST_TOO_LARGE"
+	CodeServiceUnavailable         Code = "SERVICE_UNAVAILABLE"
+	CodeInternal                   Code = "INTERNAL_ERROR"
+)
+
+var (
+	ErrPlanNotFound               = New(CodePlanNotFound, "plan does not exist")
+	ErrPlanAlreadyExists          = New(CodePlanAlreadyExists, "a plan with this code already exists")
+	ErrPlanModified               = New(CodePlanModified, "plan was modified since the given version")
+	ErrSubscriptionNotFound       = New(CodeSubscriptionNotFound, "no matching active subscription")
+	ErrSubscriptionAlreadyExists  = New(CodeSubscriptionAlreadyExists, "customer already has an active subscription")
+	ErrSubscriptionAlreadyRenewed = New(CodeSubscriptionAlreadyRenewed, "subscription period was already renewed")
+	ErrIdempotencyKeyReused       = New(CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+	ErrIdempotencyKeyInProgress   = New(CodeIdempotencyKeyInProgress, "a request with this Idempotency-Key is still being processed")
+	ErrRequestTooLarge            = New(CodeRequestTooLarge, "request body is too large")
+	ErrServiceUnavailable         = New(CodeServiceUnavailable, "service temporarily unavailable")
+)
+
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+	// Fields lists every invalid request field of a validation error.
+	Fields []FieldError
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches a client facing code and message to an underlying error. The
+// wrapped error is kept for logging and errors.As but never shown to clients.
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// Validation reports a problem with the request itself.
+func Validation(format string, args ...any) *Error {
+	return New(CodeValidationFailed, fmt.Sprintf(format, args...))
+}
+
+// FieldError explains why a single request field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors collects the invalid fields of a request so that all of them
+// are reported at once.
+type FieldErrors []FieldError
+
+func (f *FieldErrors) Add(field, format string, args ...any) {
+	*f = append(*f, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns a validation error listing the collected fields, or nil when
+// there are none.
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	messages := make([]string, len(f))
+	for i, field := range f {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return &Error{Code: CodeValidationFailed, Message: strings.Join(messages, "; "), Fields: f}
+}
+
+// FieldsOf returns the invalid fields listed by err, or nil when err is not
+// a validation error naming fields.
+func FieldsOf(err error) FieldErrors {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match any *Error with the same code, so callers can
+// compare against the sentinels above regardless of message or cause.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// CodeOf returns the code of the first *Error in err's chain, or CodeInternal.
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,63 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsMatchesByCode(t *testing.T) {
+	err := fmt.Errorf("creating subscription: %w", New(CodeSubscriptionAlreadyExists, "customer 42 is already subscribed"))
+	if !errors.Is(err, ErrSubscriptionAlreadyExists) {
+		t.Fatalf("Expected %v to match ErrSubscriptionAlreadyExists", err)
+	}
+	if errors.Is(err, ErrPlanNotFound) {
+		t.Fatalf("Did not expect %v to match ErrPlanNotFound", err)
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	cause := errors.New("dial tcp: connection refused")
+	testCases := []struct {
+		err  error
+		want Code
+	}{
+		{Validation("invalid %s", "plan_id"), CodeValidationFailed},
+		{Wrap(CodeServiceUnavailable, "database unavailable", cause), CodeServiceUnavailable},
+		{fmt.Errorf("wrapped: %w", ErrPlanNotFound), CodePlanNotFound},
+		{cause, CodeInternal},
+	}
+	for _, tc := range testCases {
+		if got := CodeOf(tc.err); got != tc.want {
+			t.Errorf("CodeOf(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestWrapKeepsCause(t *testing.T) {
+	cause := errors.New("dial tcp: connection refused")
+	err := Wrap(CodeServiceUnavailable, "database unavailable", cause)
+	if !errors.Is(err, cause) {
+		t.Fatalf("Expected wrapped error to unwrap to its cause")
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	var fields FieldErrors
+	if err := fields.Err(); err != nil {
+		t.Fatalf("Expected no error without fields, got %v", err)
+	}
+	fields.Add("price_cents", "must not be negative")
+	fields.Add("currency", "must be an ISO 4217 currency code, like %s", "USD")
+	err := fields.Err()
+	var appErr *Error
+	if !errors.As(err, &appErr) || appErr.Code != CodeValidationFailed {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if want := "price_cents must not be negative; currency must be an ISO 4217 currency code, like USD"; appErr.Message != want {
+		t.Errorf("Expected message %q, got %q", want, appErr.Message)
+	}
+	if len(appErr.Fields) != 2 || appErr.Fields[1].Field != "currency" {
+		t.Errorf("Expected both fields to be kept, got %+v", appErr.Fields)
+	}
+}
@@ -2,21 +2,213 @@ package main
 
 import (
 	"bss/src/database"
+	"bss/src/database/memory"
+	"bss/src/health"
+	"bss/src/metrics"
+	"bss/src/outbox"
+	"bss/src/scheduler"
 	"bss/src/server"
+	"bss/src/service"
+	"bss/src/tracing"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
+// durationFromEnv reads a time.ParseDuration value such as "30s" from the
+// environment, falling back to defaultValue when unset or invalid.
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("invalid %s %q, using %s\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// intFromEnv reads a positive integer from the environment, falling back to
+// defaultValue when unset or invalid.
+func intFromEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		fmt.Printf("invalid %s %q, using %d\n", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// store is everything main wires up against, satisfied by both the Postgres
+// and the in-memory database.
+type store interface {
+	server.Database
+	server.IdempotencyStore
+	outbox.Store
+	scheduler.IdempotencyStore
+	metrics.ActiveSubscriptionStore
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// openDatabase connects to Postgres unless DB_BACKEND=memory, which runs the
+// server against an empty in-memory store that is lost on exit.
+func openDatabase(ctx context.Context) (store, error) {
+	if os.Getenv("DB_BACKEND") == "memory" {
+		fmt.Println("DB_BACKEND=memory, data will not be persisted")
+		return memory.New(), nil
+	}
+	return database.NewDb(ctx)
+}
+
 func main() {
-	db, err := database.NewDb(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	shutdownTracing, err := tracing.Setup(ctx)
 	if err != nil {
 		panic(err)
 	}
-	server := server.NewServer(db)
-	addr := ":" + os.Getenv("APP_PORT")
-	fmt.Println("Starting BSS Server... on port", addr)
-	if err := server.Start(addr); err != nil {
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Println("failed to flush traces:", err)
+		}
+	}()
+	db, err := openDatabase(ctx)
+	if err != nil {
 		panic(err)
 	}
+	defer db.Close()
+	checks := health.NewRegistry()
+	checkTimeout := durationFromEnv("HEALTH_CHECK_TIMEOUT", health.DefaultTimeout)
+	checks.Register("database", checkTimeout, db.Ping)
+	if migrated, ok := db.(interface{ CheckMigrations(context.Context) error }); ok {
+		checks.Register("migrations", checkTimeout, migrated.CheckMigrations)
+	}
+	background, stopBackground := context.WithCancel(context.Background())
+	var relayDone <-chan struct{}
+	if brokers := os.Getenv("KAFKA_BROKERS"); brokers != "" {
+		publisher := outbox.NewKafkaPublisher(brokers)
+		defer publisher.Close()
+		checks.Register("event_publisher", checkTimeout, publisher.Ping)
+		relayDone = runInBackground(func() { outbox.NewRelay(db, publisher).Run(background) })
+	} else {
+		fmt.Println("KAFKA_BROKERS not set, outbox relay disabled")
+	}
+	renewalPolicy, err := service.ParseRenewalVersionPolicy(envOrDefault("RENEWAL_VERSION_POLICY", string(service.RenewOnLatestVersion)))
+	if err != nil {
+		panic(err)
+	}
+	appMetrics := metrics.New()
+	appMetrics.RegisterActiveSubscriptions(db, checkTimeout)
+	if postgres, ok := db.(*database.DB); ok {
+		appMetrics.RegisterPool(postgres.Pool)
+	}
+	plans := service.NewPlanService(db)
+	subscriptions := service.NewSubscriptionService(db,
+		service.WithRenewalVersionPolicy(renewalPolicy),
+		service.WithSubscriptionMetrics(appMetrics),
+	)
+	jobs := scheduler.New(
+		scheduler.RenewalJob(subscriptions, durationFromEnv("RENEWAL_SWEEP_INTERVAL", time.Minute), 500),
+		scheduler.ExpiryJob(subscriptions, durationFromEnv("EXPIRY_SWEEP_INTERVAL", time.Minute), 500),
+		scheduler.IdempotencyCleanupJob(db, time.Hour),
+	)
+	jobsDone := runInBackground(func() { jobs.Run(background) })
+	metricsPort := os.Getenv("PROMETHEUS_PORT")
+	api := server.NewServer(plans, subscriptions, db,
+		server.WithIdempotency(db, durationFromEnv("IDEMPOTENCY_TTL", 24*time.Hour)),
+		server.WithMaxPageSize(intFromEnv("MAX_PAGE_SIZE", 100)),
+		server.WithHealth(checks),
+		server.WithMetrics(appMetrics, metricsPort == ""),
+	)
+	httpServer := &http.Server{
+		Addr:              ":" + os.Getenv("APP_PORT"),
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	servers := []*http.Server{httpServer}
+	if metricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", appMetrics.Handler())
+		servers = append(servers, &http.Server{
+			Addr:         ":" + metricsPort,
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		})
+		fmt.Println("Serving metrics on port", metricsPort)
+	}
+	serverErr := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() { serverErr <- srv.ListenAndServe() }()
+	}
+	fmt.Println("Starting BSS Server... on port", httpServer.Addr)
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	case <-ctx.Done():
+		shutdown(servers, checks, stopBackground, relayDone, jobsDone)
+	}
+}
+
+func runInBackground(fn func()) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	return done
+}
+
+// shutdown fails readiness and keeps serving for SHUTDOWN_DRAIN_DELAY so
+// load balancers stop routing here, then waits up to SHUTDOWN_TIMEOUT for
+// in-flight requests on every server before stopping the outbox relay and
+// scheduler.
+func shutdown(servers []*http.Server, checks *health.Registry, stopBackground context.CancelFunc, done ...<-chan struct{}) {
+	fmt.Println("Shutting down BSS Server...")
+	checks.Shutdown()
+	time.Sleep(durationFromEnv("SHUTDOWN_DRAIN_DELAY", 5*time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), durationFromEnv("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			fmt.Printf("failed to drain requests on %s: %v\n", srv.Addr, err)
+		}
+	}
+	stopBackground()
+	for _, d := range done {
+		if d == nil {
+			continue
+		}
+		select {
+		case <-d:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
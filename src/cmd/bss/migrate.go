@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bss/src/database"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const migrateUsage = "usage: bss migrate up | down [n] | status"
+
+// runMigrate implements `bss migrate`, which applies, reverts or lists the
+// schema migrations embedded in the binary.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if os.Getenv("DB_BACKEND") == "memory" {
+		return errors.New("DB_BACKEND=memory has no schema to migrate")
+	}
+	db, err := database.NewDb(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		for _, migration := range applied {
+			fmt.Println("applied", migration)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return errors.New(migrateUsage)
+			}
+		}
+		reverted, err := db.MigrateDown(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Println("reverted", migration)
+		}
+		return err
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if state.AppliedAt != nil {
+				fmt.Printf("%s applied %s\n", state.Migration, state.AppliedAt.Format(time.RFC3339))
+			} else {
+				fmt.Printf("%s pending\n", state.Migration)
+			}
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
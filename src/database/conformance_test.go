@@ -0,0 +1,74 @@
+package database_test
+
+import (
+	"bss/src/database"
+	"bss/src/database/databasetest"
+	"bss/src/server"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	db, err := database.NewDb(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	databasetest.Run(t, func(t *testing.T) server.Database {
+		return db
+	})
+}
+
+// TestConformanceSweeps gives every test a freshly migrated schema of its
+// own, so that sweeping the due rows leaves the shared data alone.
+func TestConformanceSweeps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+	db, err := database.NewDb(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	databasetest.RunSweeps(t, func(t *testing.T) server.Database {
+		return newSchema(t, db)
+	})
+}
+
+func newSchema(t *testing.T, db *database.DB) *database.DB {
+	t.Helper()
+	ctx := context.Background()
+	schema := "conformance_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := db.Pool.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Pool.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+			t.Errorf("Failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(db.Pool.Config().ConnString())
+	if err != nil {
+		t.Fatalf("Failed to parse connection string: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("Failed to connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(pool.Close)
+
+	fresh := &database.DB{Pool: pool}
+	if _, err := fresh.MigrateUp(ctx); err != nil {
+		t.Fatalf("Failed to migrate schema %s: %v", schema, err)
+	}
+	return fresh
+}
@@ -3,7 +3,14 @@ package database
 import "bss/src/models"
 
 type PageableRequest = models.PageableRequest
-type Page[V any] models.Page[V]
+type SortField = models.SortField
+type Page[V any] = models.Page[V]
 type Plan = models.Plan
+type PlanVersion = models.PlanVersion
+type PlanFilter = models.PlanFilter
 type Subscription = models.Subscription
+type PlanSubscriptionCount = models.PlanSubscriptionCount
+type SubscriptionStatus = models.SubscriptionStatus
 type Event = models.Event
+type EventFilter = models.EventFilter
+type IdempotencyRecord = models.IdempotencyRecord
@@ -0,0 +1,1015 @@
+// Package databasetest is a conformance suite for implementations of
+// server.Database. Both database.DB and memory.DB run it, so the in-memory
+// store used by handler tests cannot drift from Postgres.
+//
+// The tests in Run only rely on data they create themselves, so they can run
+// against a shared database that already holds other rows. The ones in
+// RunSweeps expire, renew or publish every due row, so they need a database of
+// their own.
+package databasetest
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"bss/src/server"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Plan = models.Plan
+type Subscription = models.Subscription
+
+// Run runs every conformance test against the database returned by newDB.
+func Run(t *testing.T, newDB func(t *testing.T) server.Database) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db server.Database)
+	}{
+		{"CreateAndGetPlan", testCreateAndGetPlan},
+		{"PlanNotFound", testPlanNotFound},
+		{"DuplicatePlanCode", testDuplicatePlanCode},
+		{"UpdatePlan", testUpdatePlan},
+		{"PlanVersions", testPlanVersions},
+		{"GetPlansPagination", testGetPlansPagination},
+		{"GetPlansFilterAndSort", testGetPlansFilterAndSort},
+		{"SubscriptionPagination", testSubscriptionPagination},
+		{"SubscriptionCursorPagination", testSubscriptionCursorPagination},
+		{"PlanCursorPagination", testPlanCursorPagination},
+		{"SubscriptionUnknownPlan", testSubscriptionUnknownPlan},
+		{"OneActiveSubscriptionPerCustomer", testOneActiveSubscriptionPerCustomer},
+		{"CancelChecksOwnership", testCancelChecksOwnership},
+		{"CountActiveSubscriptionsByPlan", testCountActiveSubscriptionsByPlan},
+		{"RenewPeriodOnce", testRenewPeriodOnce},
+		{"WithinTxRollback", testWithinTxRollback},
+		{"StreamEvents", testStreamEvents},
+		{"Idempotency", testIdempotency},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newDB(t))
+		})
+	}
+}
+
+// RunSweeps runs the conformance tests that act on every due row in the
+// database. newDB must return a database that no other test uses.
+func RunSweeps(t *testing.T, newDB func(t *testing.T) server.Database) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, db server.Database)
+	}{
+		{"ExpireAndRenewDueSubscriptions", testExpireAndRenewDueSubscriptions},
+		{"RetirePlan", testRetirePlan},
+		{"PublishPendingEventsDeadLetters", testPublishPendingEventsDeadLetters},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newDB(t))
+		})
+	}
+}
+
+func newPlan(t *testing.T, db server.Database, createdAt time.Time) Plan {
+	t.Helper()
+	plan, err := db.CreatePlan(context.Background(), Plan{
+		Code:         fmt.Sprintf("CONF-%s", uuid.NewString()[:18]),
+		Name:         "Conformance Plan",
+		PriceCents:   999,
+		Currency:     "USD",
+		DurationDays: 30,
+		DataMB:       5120,
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create plan: %v", err)
+	}
+	return plan
+}
+
+func newSubscription(t *testing.T, db server.Database, customerId uuid.UUID, plan Plan, status models.SubscriptionStatus, endDate time.Time, createdAt time.Time) Subscription {
+	t.Helper()
+	subscription, err := db.CreateSubscription(context.Background(), Subscription{
+		CustomerID: customerId,
+		PlanID:     plan.ID,
+		StartDate:  endDate.AddDate(0, 0, -plan.DurationDays),
+		EndDate:    endDate,
+		Status:     status,
+		AutoRenew:  false,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	return subscription
+}
+
+func expectError(t *testing.T, err error, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("Expected %v, got %v", want, err)
+	}
+}
+
+func expectTotal[V any](t *testing.T, page models.Page[V], want int64) {
+	t.Helper()
+	if page.TotalCount == nil {
+		t.Fatalf("Expected total count %d, got none", want)
+	}
+	if *page.TotalCount != want {
+		t.Errorf("Expected total count %d, got %d", want, *page.TotalCount)
+	}
+}
+
+func testCreateAndGetPlan(t *testing.T, db server.Database) {
+	created := newPlan(t, db, time.Now())
+	if created.ID == uuid.Nil {
+		t.Fatalf("Expected an id to be assigned")
+	}
+	if !created.Active {
+		t.Errorf("Expected new plans to be active")
+	}
+	fetched, err := db.GetPlan(context.Background(), created.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to get plan: %v", err)
+	}
+	if fetched.Code != created.Code || fetched.PriceCents != created.PriceCents || !fetched.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected %+v, got %+v", created, fetched)
+	}
+	byCode, err := db.GetPlanByCode(context.Background(), created.Code)
+	if err != nil {
+		t.Fatalf("Failed to get plan by code: %v", err)
+	}
+	if byCode.ID != created.ID {
+		t.Errorf("Expected plan %s, got %s", created.ID, byCode.ID)
+	}
+}
+
+func testPlanNotFound(t *testing.T, db server.Database) {
+	_, err := db.GetPlan(context.Background(), uuid.NewString())
+	expectError(t, err, apperrors.ErrPlanNotFound)
+	_, err = db.GetPlan(context.Background(), "BASIC-MONTHLY")
+	expectError(t, err, apperrors.ErrPlanNotFound)
+	_, err = db.GetPlanByCode(context.Background(), "CONF-MISSING")
+	expectError(t, err, apperrors.ErrPlanNotFound)
+	_, err = db.UpdatePlan(context.Background(), Plan{ID: uuid.New(), Code: "CONF-MISSING", Name: "Missing", DurationDays: 1})
+	expectError(t, err, apperrors.ErrPlanNotFound)
+}
+
+func testDuplicatePlanCode(t *testing.T, db server.Database) {
+	plan := newPlan(t, db, time.Now())
+	duplicate := plan
+	duplicate.ID = uuid.Nil
+	_, err := db.CreatePlan(context.Background(), duplicate)
+	expectError(t, err, apperrors.ErrPlanAlreadyExists)
+}
+
+func testUpdatePlan(t *testing.T, db server.Database) {
+	plan := newPlan(t, db, time.Now().Add(-time.Hour))
+	plan.Name = "Updated Conformance Plan"
+	plan.PriceCents = 1999
+	plan.Active = false
+	plan.UpdatedAt = time.Now()
+	updated, err := db.UpdatePlan(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Failed to update plan: %v", err)
+	}
+	fetched, err := db.GetPlan(context.Background(), plan.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to get plan: %v", err)
+	}
+	if fetched.Name != plan.Name || fetched.PriceCents != 1999 || fetched.Active {
+		t.Errorf("Expected update to be stored, got %+v", fetched)
+	}
+	if !fetched.CreatedAt.Equal(updated.CreatedAt) || !fetched.UpdatedAt.Equal(updated.UpdatedAt) {
+		t.Errorf("Expected returned and stored timestamps to match: %+v vs %+v", updated, fetched)
+	}
+
+	// plan still carries version 1, which updated has replaced.
+	plan.Name = "Lost Update"
+	_, err = db.UpdatePlan(context.Background(), plan)
+	expectError(t, err, apperrors.ErrPlanModified)
+	plan.Version = 0
+	if _, err := db.UpdatePlan(context.Background(), plan); err != nil {
+		t.Errorf("Expected an unversioned update to succeed, got %v", err)
+	}
+}
+
+func testPlanVersions(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := newPlan(t, db, time.Now())
+	if plan.Version != 1 {
+		t.Fatalf("Expected a new plan to be version 1, got %d", plan.Version)
+	}
+	customerId := uuid.New()
+	onFirst := newSubscription(t, db, customerId, plan, models.SubscriptionStatusExpired, time.Now(), time.Now())
+
+	plan.PriceCents = 1499
+	plan.DurationDays = 60
+	plan.UpdatedAt = time.Now()
+	updated, err := db.UpdatePlan(ctx, plan)
+	if err != nil {
+		t.Fatalf("Failed to update plan: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("Expected the update to create version 2, got %d", updated.Version)
+	}
+
+	versions, err := db.GetPlanVersions(ctx, plan.ID.String())
+	if err != nil {
+		t.Fatalf("Failed to get versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("Expected versions 2 and 1, got %+v", versions)
+	}
+	if versions[0].PriceCents != 1499 || versions[0].DurationDays != 60 || versions[1].PriceCents != 999 || versions[1].DurationDays != 30 {
+		t.Errorf("Expected each version to keep its own terms, got %+v", versions)
+	}
+	first, err := db.GetPlanVersion(ctx, plan.ID.String(), 1)
+	if err != nil {
+		t.Fatalf("Failed to get version 1: %v", err)
+	}
+	if first != versions[1] {
+		t.Errorf("Expected %+v, got %+v", versions[1], first)
+	}
+
+	if onFirst.PlanVersion != 1 {
+		t.Errorf("Expected a zero PlanVersion to default to the current version 1, got %d", onFirst.PlanVersion)
+	}
+	onLatest := newSubscription(t, db, customerId, updated, models.SubscriptionStatusExpired, time.Now(), time.Now())
+	if onLatest.PlanVersion != 2 {
+		t.Errorf("Expected the current version 2, got %d", onLatest.PlanVersion)
+	}
+	pinned, err := db.CreateSubscription(ctx, Subscription{
+		CustomerID:  customerId,
+		PlanID:      plan.ID,
+		PlanVersion: 1,
+		Status:      models.SubscriptionStatusExpired,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create a subscription on version 1: %v", err)
+	}
+	if pinned.PlanVersion != 1 {
+		t.Errorf("Expected an explicit version to be kept, got %d", pinned.PlanVersion)
+	}
+	_, err = db.CreateSubscription(ctx, Subscription{
+		CustomerID:  customerId,
+		PlanID:      plan.ID,
+		PlanVersion: 3,
+		Status:      models.SubscriptionStatusExpired,
+	})
+	expectError(t, err, apperrors.ErrPlanNotFound)
+
+	_, err = db.GetPlanVersions(ctx, uuid.NewString())
+	expectError(t, err, apperrors.ErrPlanNotFound)
+	_, err = db.GetPlanVersion(ctx, plan.ID.String(), 3)
+	expectError(t, err, apperrors.ErrPlanNotFound)
+}
+
+func testGetPlansPagination(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	// The plans share a random code prefix, so filtering on it pages through
+	// them alone whatever else is in the database.
+	filter := models.PlanFilter{CodePrefix: fmt.Sprintf("P%s-", uuid.NewString()[:8])}
+	base := time.Now()
+	create := func(n int) Plan {
+		created := base.Add(time.Duration(n) * time.Second)
+		plan, err := db.CreatePlan(ctx, Plan{
+			Code:         fmt.Sprintf("%s%d", filter.CodePrefix, n),
+			Name:         "Conformance Plan",
+			PriceCents:   999,
+			Currency:     "USD",
+			DurationDays: 30,
+			CreatedAt:    created,
+			UpdatedAt:    created,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+		return plan
+	}
+	oldest := create(0)
+	middle := create(1)
+	newest := create(2)
+
+	first, err := db.GetPlans(ctx, filter, models.PageableRequest{Page: 1, PageSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to get plans: %v", err)
+	}
+	expectTotal(t, first, 3)
+	if len(first.Items) != 2 || first.Items[0].ID != newest.ID || first.Items[1].ID != middle.ID {
+		t.Fatalf("Expected [%s %s] on page 1, got %v", newest.ID, middle.ID, first.Items)
+	}
+	second, err := db.GetPlans(ctx, filter, models.PageableRequest{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to get plans: %v", err)
+	}
+	if len(second.Items) != 1 || second.Items[0].ID != oldest.ID {
+		t.Fatalf("Expected only %s on page 2, got %v", oldest.ID, second.Items)
+	}
+}
+
+func testGetPlansFilterAndSort(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	// Every plan shares a random code prefix, so filtering on it isolates
+	// this test from other rows.
+	prefix := fmt.Sprintf("F%s-", uuid.NewString()[:8])
+	created := time.Now()
+	create := func(suffix, name string, price int64, currency string, days int, active bool) Plan {
+		created = created.Add(time.Second)
+		plan, err := db.CreatePlan(ctx, Plan{
+			Code:         prefix + suffix,
+			Name:         name,
+			PriceCents:   price,
+			Currency:     currency,
+			DurationDays: days,
+			CreatedAt:    created,
+			UpdatedAt:    created,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+		if !active {
+			plan.Active = false
+			if plan, err = db.UpdatePlan(ctx, plan); err != nil {
+				t.Fatalf("Failed to deactivate plan: %v", err)
+			}
+		}
+		return plan
+	}
+	basic := create("BASIC", "Basic Monthly", 500, "USD", 30, true)
+	premium := create("PREMIUM", "Premium Monthly", 2000, "USD", 30, true)
+	yearly := create("YEARLY", "Premium Yearly", 20000, "EUR", 365, true)
+	legacy := create("LEGACY", "legacy 50%_off", 100, "USD", 30, false)
+
+	active, inactive := true, false
+	ptr64 := func(v int64) *int64 { return &v }
+	ptr := func(v int) *int { return &v }
+	testCases := []struct {
+		name   string
+		filter models.PlanFilter
+		want   []Plan
+	}{
+		{"CodePrefixOnly", models.PlanFilter{}, []Plan{basic, legacy, premium, yearly}},
+		{"Active", models.PlanFilter{Active: &active}, []Plan{basic, premium, yearly}},
+		{"Inactive", models.PlanFilter{Active: &inactive}, []Plan{legacy}},
+		{"Currency", models.PlanFilter{Currency: "EUR"}, []Plan{yearly}},
+		{"PriceRange", models.PlanFilter{MinPriceCents: ptr64(500), MaxPriceCents: ptr64(2000)}, []Plan{basic, premium}},
+		{"DurationRange", models.PlanFilter{MinDurationDays: ptr(31)}, []Plan{yearly}},
+		{"NameSearchIsCaseInsensitive", models.PlanFilter{Name: "premium"}, []Plan{premium, yearly}},
+		{"NameSearchEscapesWildcards", models.PlanFilter{Name: "50%_"}, []Plan{legacy}},
+		{"NameSearchWildcardIsLiteral", models.PlanFilter{Name: "%"}, []Plan{legacy}},
+		{"Combined", models.PlanFilter{Active: &active, Currency: "USD", MaxDurationDays: ptr(30)}, []Plan{basic, premium}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.filter.CodePrefix = prefix
+			tc.filter.Sort = []models.SortField{{Field: "code"}}
+			page, err := db.GetPlans(ctx, tc.filter, models.PageableRequest{Page: 1, PageSize: 10})
+			if err != nil {
+				t.Fatalf("Failed to get plans: %v", err)
+			}
+			expectTotal(t, page, int64(len(tc.want)))
+			if len(page.Items) != len(tc.want) {
+				t.Fatalf("Expected %d plans, got %v", len(tc.want), page.Items)
+			}
+			for i, plan := range tc.want {
+				if page.Items[i].ID != plan.ID {
+					t.Errorf("Expected %s at %d, got %s", plan.Code, i, page.Items[i].Code)
+				}
+			}
+		})
+	}
+
+	sortCases := []struct {
+		name string
+		sort string
+		want []Plan
+	}{
+		{"PriceAscending", "price_cents", []Plan{legacy, basic, premium, yearly}},
+		{"PriceDescending", "-price_cents", []Plan{yearly, premium, basic, legacy}},
+		{"MultipleFields", "duration_days,-price_cents", []Plan{premium, basic, legacy, yearly}},
+		{"NameByteOrder", "name", []Plan{basic, premium, yearly, legacy}},
+		{"NameByteOrderDescending", "-name", []Plan{legacy, yearly, premium, basic}},
+		{"Default", "", []Plan{legacy, yearly, premium, basic}},
+	}
+	for _, tc := range sortCases {
+		t.Run("Sort"+tc.name, func(t *testing.T) {
+			filter := models.PlanFilter{CodePrefix: prefix, Sort: models.ParseSort(tc.sort)}
+			page, err := db.GetPlans(ctx, filter, models.PageableRequest{Page: 1, PageSize: 10})
+			if err != nil {
+				t.Fatalf("Failed to get plans: %v", err)
+			}
+			if len(page.Items) != len(tc.want) {
+				t.Fatalf("Expected %d plans, got %v", len(tc.want), page.Items)
+			}
+			for i, plan := range tc.want {
+				if page.Items[i].ID != plan.ID {
+					t.Errorf("Expected %s at %d, got %s", plan.Code, i, page.Items[i].Code)
+				}
+			}
+		})
+	}
+
+	for _, sort := range []string{"password", "price_cents;DROP TABLE plans", "-"} {
+		_, err := db.GetPlans(ctx, models.PlanFilter{Sort: models.ParseSort(sort)}, models.PageableRequest{Page: 1, PageSize: 10})
+		if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+			t.Errorf("Expected sort %q to be rejected, got %v", sort, err)
+		}
+	}
+}
+
+func testSubscriptionPagination(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := newPlan(t, db, time.Now())
+	customerId := uuid.New()
+	now := time.Now()
+	var created []Subscription
+	for i := 0; i < 3; i++ {
+		created = append(created, newSubscription(t, db, customerId, plan, models.SubscriptionStatusExpired, now, now.Add(time.Duration(i)*time.Second)))
+	}
+
+	page, err := db.GetSubscriptionsByUserId(ctx, models.PageableRequest{Page: 1, PageSize: 2}, customerId.String())
+	if err != nil {
+		t.Fatalf("Failed to get subscriptions: %v", err)
+	}
+	expectTotal(t, page, 3)
+	if len(page.Items) != 2 || page.Items[0].ID != created[2].ID || page.Items[1].ID != created[1].ID {
+		t.Fatalf("Unexpected page 1: %v", page.Items)
+	}
+	page, err = db.GetSubscriptionsByUserId(ctx, models.PageableRequest{Page: 2, PageSize: 2}, customerId.String())
+	if err != nil {
+		t.Fatalf("Failed to get subscriptions: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != created[0].ID {
+		t.Fatalf("Unexpected page 2: %v", page.Items)
+	}
+	page, err = db.GetSubscriptionsByUserId(ctx, models.PageableRequest{Page: 1, PageSize: 2}, uuid.NewString())
+	if err != nil {
+		t.Fatalf("Failed to get subscriptions: %v", err)
+	}
+	expectTotal(t, page, 0)
+	if page.Items == nil || len(page.Items) != 0 {
+		t.Fatalf("Expected an empty page for an unknown customer, got %+v", page)
+	}
+}
+
+func testSubscriptionCursorPagination(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := newPlan(t, db, time.Now())
+	customerId := uuid.New()
+	now := time.Now()
+	want := map[uuid.UUID]bool{}
+	for i := 0; i < 7; i++ {
+		// Pairs of equal creation times exercise the id tie-breaker.
+		created := newSubscription(t, db, customerId, plan, models.SubscriptionStatusExpired, now, now.Add(time.Duration(i/2)*time.Second))
+		want[created.ID] = true
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var previous *Subscription
+	request := models.PageableRequest{Page: 1, PageSize: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatalf("Cursor pagination did not terminate")
+		}
+		page, err := db.GetSubscriptionsByUserId(ctx, request, customerId.String())
+		if err != nil {
+			t.Fatalf("Failed to get subscriptions: %v", err)
+		}
+		if request.Cursor != "" && page.TotalCount != nil {
+			t.Errorf("Expected no total count in cursor mode, got %d", *page.TotalCount)
+		}
+		for _, subscription := range page.Items {
+			if seen[subscription.ID] {
+				t.Errorf("Subscription %s returned twice", subscription.ID)
+			}
+			seen[subscription.ID] = true
+			if previous != nil && subscription.Cursor().Compare(previous.Cursor()) >= 0 {
+				t.Errorf("Expected %s to sort after %s", subscription.ID, previous.ID)
+			}
+			previous = &subscription
+		}
+		if pages == 0 {
+			// Rows added while paging are newer than the cursor and must
+			// not shift later pages.
+			newSubscription(t, db, customerId, plan, models.SubscriptionStatusExpired, now, now.Add(time.Hour))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		request.Cursor = page.NextCursor
+	}
+	if len(seen) != len(want) {
+		t.Errorf("Expected %d subscriptions, saw %d", len(want), len(seen))
+	}
+	for id := range want {
+		if !seen[id] {
+			t.Errorf("Subscription %s was skipped", id)
+		}
+	}
+
+	_, err := db.GetSubscriptionsByUserId(ctx, models.PageableRequest{PageSize: 2, Cursor: "not-a-cursor"}, customerId.String())
+	if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+		t.Errorf("Expected an invalid cursor to be rejected, got %v", err)
+	}
+}
+
+func testPlanCursorPagination(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("C%s-", uuid.NewString()[:8])
+	created := time.Now()
+	var want []uuid.UUID
+	for i := 0; i < 5; i++ {
+		plan, err := db.CreatePlan(ctx, Plan{
+			Code:         fmt.Sprintf("%s%d", prefix, i),
+			Name:         "Cursor Plan",
+			Currency:     "USD",
+			DurationDays: 30,
+			CreatedAt:    created.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+		want = append([]uuid.UUID{plan.ID}, want...)
+	}
+
+	filter := models.PlanFilter{CodePrefix: prefix}
+	first, err := db.GetPlans(ctx, filter, models.PageableRequest{Page: 1, PageSize: 3})
+	if err != nil {
+		t.Fatalf("Failed to get plans: %v", err)
+	}
+	expectTotal(t, first, 5)
+	if first.NextCursor == "" {
+		t.Fatalf("Expected a next cursor after the first page")
+	}
+	second, err := db.GetPlans(ctx, filter, models.PageableRequest{PageSize: 3, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("Failed to get plans: %v", err)
+	}
+	var got []uuid.UUID
+	for _, plan := range append(first.Items, second.Items...) {
+		got = append(got, plan.ID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if second.NextCursor != "" || second.TotalCount != nil {
+		t.Errorf("Expected the last cursor page to have no cursor or total, got %+v", second)
+	}
+
+	filter.Sort = models.ParseSort("price_cents")
+	sorted, err := db.GetPlans(ctx, filter, models.PageableRequest{Page: 1, PageSize: 3})
+	if err != nil {
+		t.Fatalf("Failed to get plans: %v", err)
+	}
+	if sorted.NextCursor != "" {
+		t.Errorf("Expected no cursor for a custom sort")
+	}
+	_, err = db.GetPlans(ctx, filter, models.PageableRequest{PageSize: 3, Cursor: first.NextCursor})
+	if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+		t.Errorf("Expected cursor with sort to be rejected, got %v", err)
+	}
+}
+
+func testSubscriptionUnknownPlan(t *testing.T, db server.Database) {
+	_, err := db.CreateSubscription(context.Background(), Subscription{
+		CustomerID: uuid.New(),
+		PlanID:     uuid.New(),
+		StartDate:  time.Now(),
+		EndDate:    time.Now().AddDate(0, 0, 30),
+		Status:     models.SubscriptionStatusActive,
+	})
+	expectError(t, err, apperrors.ErrPlanNotFound)
+}
+
+func testOneActiveSubscriptionPerCustomer(t *testing.T, db server.Database) {
+	plan := newPlan(t, db, time.Now())
+	customerId := uuid.New()
+	end := time.Now().AddDate(0, 0, 30)
+	newSubscription(t, db, customerId, plan, models.SubscriptionStatusActive, end, time.Now())
+	_, err := db.CreateSubscription(context.Background(), Subscription{
+		CustomerID: customerId,
+		PlanID:     plan.ID,
+		StartDate:  time.Now(),
+		EndDate:    end,
+		Status:     models.SubscriptionStatusActive,
+	})
+	expectError(t, err, apperrors.ErrSubscriptionAlreadyExists)
+	// Inactive history does not count.
+	newSubscription(t, db, customerId, plan, models.SubscriptionStatusExpired, time.Now(), time.Now())
+}
+
+func testCancelChecksOwnership(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := newPlan(t, db, time.Now())
+	customerId := uuid.New()
+	subscription := newSubscription(t, db, customerId, plan, models.SubscriptionStatusActive, time.Now().AddDate(0, 0, 30), time.Now())
+
+	_, err := db.CancelSubscription(ctx, subscription.ID.String(), uuid.NewString())
+	expectError(t, err, apperrors.ErrSubscriptionNotFound)
+
+	cancelled, err := db.CancelSubscription(ctx, subscription.ID.String(), customerId.String())
+	if err != nil {
+		t.Fatalf("Failed to cancel subscription: %v", err)
+	}
+	if cancelled.ID != subscription.ID || cancelled.Status != models.SubscriptionStatusCancelled {
+		t.Errorf("Expected cancelled subscription %s, got %+v", subscription.ID, cancelled)
+	}
+
+	_, err = db.CancelSubscription(ctx, subscription.ID.String(), customerId.String())
+	expectError(t, err, apperrors.ErrSubscriptionNotFound)
+}
+
+// activeCounter is implemented by both databases for the active
+// subscriptions gauge, which the server itself does not need.
+type activeCounter interface {
+	CountActiveSubscriptionsByPlan(ctx context.Context) ([]models.PlanSubscriptionCount, error)
+}
+
+func testCountActiveSubscriptionsByPlan(t *testing.T, db server.Database) {
+	counter, ok := db.(activeCounter)
+	if !ok {
+		t.Skip("database does not count active subscriptions")
+	}
+	ctx := context.Background()
+	busy := newPlan(t, db, time.Now())
+	idle := newPlan(t, db, time.Now())
+	endDate := time.Now().AddDate(0, 0, 30)
+	newSubscription(t, db, uuid.New(), busy, models.SubscriptionStatusActive, endDate, time.Now())
+	newSubscription(t, db, uuid.New(), busy, models.SubscriptionStatusActive, endDate, time.Now())
+	newSubscription(t, db, uuid.New(), busy, models.SubscriptionStatusCancelled, endDate, time.Now())
+	newSubscription(t, db, uuid.New(), idle, models.SubscriptionStatusExpired, endDate, time.Now())
+
+	counts, err := counter.CountActiveSubscriptionsByPlan(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count active subscriptions: %v", err)
+	}
+	want := map[uuid.UUID]int64{busy.ID: 2, idle.ID: 0}
+	for _, count := range counts {
+		if active, ok := want[count.PlanID]; ok {
+			if count.Active != active {
+				t.Errorf("Expected %d active subscriptions on %s, got %d", active, count.PlanCode, count.Active)
+			}
+			delete(want, count.PlanID)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("Expected counts for plans %v", want)
+	}
+}
+
+func testExpireAndRenewDueSubscriptions(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := newPlan(t, db, time.Now())
+	past := time.Now().Add(-time.Hour)
+	expiring := newSubscription(t, db, uuid.New(), plan, models.SubscriptionStatusActive, past, time.Now())
+	renewing, err := db.CreateSubscription(ctx, Subscription{
+		CustomerID: uuid.New(),
+		PlanID:     plan.ID,
+		StartDate:  past.AddDate(0, 0, -30),
+		EndDate:    past,
+		Status:     models.SubscriptionStatusActive,
+		AutoRenew:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	current := newSubscription(t, db, uuid.New(), plan, models.SubscriptionStatusActive, time.Now().AddDate(0, 0, 30), time.Now())
+
+	expired, err := db.ExpireSubscriptions(ctx, time.Now(), 10000)
+	if err != nil {
+		t.Fatalf("Failed to expire subscriptions: %v", err)
+	}
+	found := map[uuid.UUID]bool{}
+	for _, s := range expired {
+		found[s.ID] = true
+		if s.Status != models.SubscriptionStatusExpired {
+			t.Errorf("Expected %s to be EXPIRED, got %s", s.ID, s.Status)
+		}
+	}
+	if !found[expiring.ID] {
+		t.Errorf("Expected %s to be expired", expiring.ID)
+	}
+	if found[renewing.ID] || found[current.ID] {
+		t.Errorf("Expected only non-renewing due subscriptions to be expired")
+	}
+
+	err = db.WithinTx(ctx, func(ctx context.Context) error {
+		due, err := db.LockDueRenewals(ctx, time.Now(), 10000)
+		if err != nil {
+			return err
+		}
+		for _, s := range due {
+			if s.ID == renewing.ID {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected %s to be due for renewal", renewing.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRenewPeriodOnce(t *testing.T, db server.Database) {
+	plan := newPlan(t, db, time.Now())
+	previous := newSubscription(t, db, uuid.New(), plan, models.SubscriptionStatusExpired, time.Now(), time.Now())
+	renewal := Subscription{
+		CustomerID:  previous.CustomerID,
+		PlanID:      plan.ID,
+		StartDate:   previous.EndDate,
+		EndDate:     previous.EndDate.AddDate(0, 0, 30),
+		Status:      models.SubscriptionStatusActive,
+		AutoRenew:   true,
+		RenewedFrom: &previous.ID,
+	}
+	if _, err := db.CreateSubscription(context.Background(), renewal); err != nil {
+		t.Fatalf("Failed to create renewal: %v", err)
+	}
+	renewal.Status = models.SubscriptionStatusExpired
+	_, err := db.CreateSubscription(context.Background(), renewal)
+	expectError(t, err, apperrors.ErrSubscriptionAlreadyRenewed)
+}
+
+func testRetirePlan(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	successor := newPlan(t, db, time.Now())
+	_, err := db.RetirePlan(ctx, Plan{ID: uuid.New(), UpdatedAt: time.Now()})
+	expectError(t, err, apperrors.ErrPlanNotFound)
+
+	retire := func(retiredAt time.Time, successorId *uuid.UUID) Plan {
+		t.Helper()
+		plan := newPlan(t, db, time.Now())
+		plan.RetiredAt = &retiredAt
+		plan.SuccessorPlanID = successorId
+		plan.UpdatedAt = time.Now()
+		retired, err := db.RetirePlan(ctx, plan)
+		if err != nil {
+			t.Fatalf("Failed to retire plan: %v", err)
+		}
+		if retired.RetiredAt == nil || !retired.RetiredAt.Equal(retiredAt.Truncate(time.Microsecond)) || retired.Version != 1 {
+			t.Fatalf("Unexpected retired plan %+v", retired)
+		}
+		return retired
+	}
+	autoRenewing := func(plan Plan, endDate time.Time) Subscription {
+		t.Helper()
+		subscription, err := db.CreateSubscription(ctx, Subscription{
+			CustomerID: uuid.New(),
+			PlanID:     plan.ID,
+			StartDate:  endDate.AddDate(0, 0, -30),
+			EndDate:    endDate,
+			Status:     models.SubscriptionStatusActive,
+			AutoRenew:  true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+		return subscription
+	}
+
+	past := time.Now().Add(-time.Hour)
+	withoutSuccessor := autoRenewing(retire(past.Add(-time.Hour), nil), past)
+	migrating := autoRenewing(retire(past.Add(-time.Hour), &successor.ID), past)
+	retiringLater := retire(time.Now().AddDate(0, 1, 0), nil)
+	beforeRetirement := autoRenewing(retiringLater, past)
+
+	retiringLater.Name = "Renamed"
+	retiringLater.UpdatedAt = time.Now()
+	updated, err := db.UpdatePlan(ctx, retiringLater)
+	if err != nil {
+		t.Fatalf("Failed to update plan: %v", err)
+	}
+	if updated.RetiredAt == nil || !updated.RetiredAt.Equal(*retiringLater.RetiredAt) {
+		t.Errorf("Expected an update to keep the retirement, got %v", updated.RetiredAt)
+	}
+
+	expired, err := db.ExpireSubscriptions(ctx, time.Now(), 10000)
+	if err != nil {
+		t.Fatalf("Failed to expire subscriptions: %v", err)
+	}
+	expiredIds := map[uuid.UUID]bool{}
+	for _, s := range expired {
+		expiredIds[s.ID] = true
+	}
+	if !expiredIds[withoutSuccessor.ID] || expiredIds[migrating.ID] || expiredIds[beforeRetirement.ID] {
+		t.Errorf("Expected only the subscription without a successor to expire, got %v", expiredIds)
+	}
+
+	err = db.WithinTx(ctx, func(ctx context.Context) error {
+		due, err := db.LockDueRenewals(ctx, time.Now(), 10000)
+		if err != nil {
+			return err
+		}
+		dueIds := map[uuid.UUID]bool{}
+		for _, s := range due {
+			dueIds[s.ID] = true
+		}
+		if !dueIds[migrating.ID] || !dueIds[beforeRetirement.ID] || dueIds[withoutSuccessor.ID] {
+			return fmt.Errorf("expected the migrating and not yet retired subscriptions to be due, got %v", dueIds)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWithinTxRollback(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	rollback := errors.New("rollback")
+	var plan Plan
+	err := db.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		plan, err = db.CreatePlan(ctx, Plan{
+			Code:         fmt.Sprintf("CONF-TX-%s", uuid.NewString()[:8]),
+			Name:         "Rolled Back",
+			Currency:     "USD",
+			DurationDays: 30,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := db.GetPlan(ctx, plan.ID.String()); err != nil {
+			return fmt.Errorf("plan not visible inside its transaction: %w", err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Expected the rollback error, got %v", err)
+	}
+	_, err = db.GetPlan(ctx, plan.ID.String())
+	expectError(t, err, apperrors.ErrPlanNotFound)
+}
+
+func testStreamEvents(t *testing.T, db server.Database) {
+	ctx := context.Background()
+	plan := Plan{ID: uuid.New()}
+	var created []models.Event
+	for _, payload := range []models.EventPayload{models.PlanCreated{Plan: plan}, models.PlanUpdated{Plan: plan}} {
+		event, err := models.NewEvent(payload)
+		if err != nil {
+			t.Fatalf("Failed to build event: %v", err)
+		}
+		if err := db.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		created = append(created, event)
+	}
+
+	collect := func(filter models.EventFilter) []models.Event {
+		var events []models.Event
+		err := db.StreamEvents(ctx, filter, func(event models.Event) error {
+			events = append(events, event)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to stream events: %v", err)
+		}
+		return events
+	}
+
+	events := collect(models.EventFilter{ResourceID: &plan.ID})
+	if len(events) != 2 || events[0].EventID != created[0].EventID || events[1].EventID != created[1].EventID {
+		t.Fatalf("Expected both events in order, got %v", events)
+	}
+	if events[0].ID >= events[1].ID {
+		t.Errorf("Expected increasing ids, got %d and %d", events[0].ID, events[1].ID)
+	}
+	if after := collect(models.EventFilter{AfterID: events[0].ID, ResourceID: &plan.ID}); len(after) != 1 || after[0].ID != events[1].ID {
+		t.Errorf("Expected only event %d after the cursor, got %v", events[1].ID, after)
+	}
+	if typed := collect(models.EventFilter{EventType: models.EventTypePlanUpdated, ResourceID: &plan.ID}); len(typed) != 1 {
+		t.Errorf("Expected one plan.updated event, got %v", typed)
+	}
+	if limited := collect(models.EventFilter{ResourceID: &plan.ID, Limit: 1}); len(limited) != 1 {
+		t.Errorf("Expected limit to be honoured, got %v", limited)
+	}
+}
+
+// eventPublisher is implemented by both databases for the outbox relay,
+// which the server itself does not need.
+type eventPublisher interface {
+	PublishPendingEvents(ctx context.Context, limit int, maxAttempts int, publish func(context.Context, models.Event) error) (int, error)
+}
+
+func testPublishPendingEventsDeadLetters(t *testing.T, db server.Database) {
+	publisher, ok := db.(eventPublisher)
+	if !ok {
+		t.Skip("database does not publish events")
+	}
+	ctx := context.Background()
+	plan := Plan{ID: uuid.New()}
+	var created []models.Event
+	for _, payload := range []models.EventPayload{models.PlanCreated{Plan: plan}, models.PlanUpdated{Plan: plan}, models.PlanRetired{Plan: plan}} {
+		event, err := models.NewEvent(payload)
+		if err != nil {
+			t.Fatalf("Failed to build event: %v", err)
+		}
+		if err := db.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+		created = append(created, event)
+	}
+	poison := created[1].EventID
+
+	// The relay stops at the first failure, so it takes a call per attempt
+	// before the rejected event is dead-lettered and the next one goes out.
+	var published []uuid.UUID
+	for i := 0; i < 5 && len(published) < 2; i++ {
+		_, err := publisher.PublishPendingEvents(ctx, 1000, 2, func(ctx context.Context, event models.Event) error {
+			if event.EventID == poison {
+				return errors.New("message too large")
+			}
+			if event.ResourceID == plan.ID {
+				published = append(published, event.EventID)
+			}
+			return nil
+		})
+		if err != nil && err.Error() != "message too large" {
+			t.Fatalf("Failed to publish events: %v", err)
+		}
+	}
+	if len(published) != 2 || published[0] != created[0].EventID || published[1] != created[2].EventID {
+		t.Fatalf("Expected the events around the rejected one to be published in order, got %v", published)
+	}
+
+	var events []models.Event
+	err := db.StreamEvents(ctx, models.EventFilter{ResourceID: &plan.ID}, func(event models.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream events: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	rejected := events[1]
+	if rejected.DeadLetteredAt == nil || rejected.PublishedAt != nil || rejected.Attempts != 2 {
+		t.Errorf("Expected the rejected event to be dead-lettered after 2 attempts, got %+v", rejected)
+	}
+	if rejected.LastError == nil || *rejected.LastError != "message too large" {
+		t.Errorf("Expected the last error to be kept, got %v", rejected.LastError)
+	}
+	for _, event := range []models.Event{events[0], events[2]} {
+		if event.PublishedAt == nil || event.Attempts != 0 {
+			t.Errorf("Expected event %d to be published on the first attempt, got %+v", event.ID, event)
+		}
+	}
+}
+
+func testIdempotency(t *testing.T, db server.Database) {
+	store, ok := db.(server.IdempotencyStore)
+	if !ok {
+		t.Skip("database does not implement server.IdempotencyStore")
+	}
+	ctx := context.Background()
+	key := "conformance-" + uuid.NewString()
+
+	if _, reserved, err := store.ReserveIdempotencyKey(ctx, key, "hash-1", time.Hour); err != nil || !reserved {
+		t.Fatalf("Expected to reserve a new key, got reserved=%v err=%v", reserved, err)
+	}
+	record, reserved, err := store.ReserveIdempotencyKey(ctx, key, "hash-2", time.Hour)
+	if err != nil || reserved {
+		t.Fatalf("Expected the key to be taken, got reserved=%v err=%v", reserved, err)
+	}
+	if record.RequestHash != "hash-1" || record.Completed {
+		t.Errorf("Expected the in-progress record for hash-1, got %+v", record)
+	}
+	headers := map[string][]string{"Content-Type": {"application/json"}, "Etag": {`"1"`}}
+	if err := store.CompleteIdempotencyKey(ctx, key, 201, headers, []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Failed to complete key: %v", err)
+	}
+	record, _, err = store.ReserveIdempotencyKey(ctx, key, "hash-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to look up key: %v", err)
+	}
+	if !record.Completed || record.StatusCode != 201 || string(record.ResponseBody) != `{"ok":true}` || !reflect.DeepEqual(record.ResponseHeaders, headers) {
+		t.Errorf("Expected the stored response, got %+v", record)
+	}
+
+	released := "conformance-" + uuid.NewString()
+	store.ReserveIdempotencyKey(ctx, released, "hash-1", time.Hour)
+	if err := store.ReleaseIdempotencyKey(ctx, released); err != nil {
+		t.Fatalf("Failed to release key: %v", err)
+	}
+	if _, reserved, _ := store.ReserveIdempotencyKey(ctx, released, "hash-1", time.Hour); !reserved {
+		t.Errorf("Expected a released key to be reservable again")
+	}
+
+	expired := "conformance-" + uuid.NewString()
+	store.ReserveIdempotencyKey(ctx, expired, "hash-1", -time.Second)
+	if _, reserved, _ := store.ReserveIdempotencyKey(ctx, expired, "hash-2", time.Hour); !reserved {
+		t.Errorf("Expected an expired key to be reservable again")
+	}
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateEvent writes an outbox row. Call it inside WithinTx together with the
+// state change the event describes, so that both commit or neither does.
+func (db *DB) CreateEvent(ctx context.Context, event Event) error {
+	query := `INSERT INTO events (event_id, event_type, resource_id, payload, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.conn(ctx).Exec(ctx, query, event.EventID, event.EventType, event.ResourceID, event.Payload, event.CreatedAt)
+	return mapError(err, nil)
+}
+
+func scanEvent(row pgx.Row) (Event, error) {
+	var event Event
+	err := row.Scan(
+		&event.ID,
+		&event.EventID,
+		&event.EventType,
+		&event.ResourceID,
+		&event.Payload,
+		&event.CreatedAt,
+		&event.PublishedAt,
+		&event.Attempts,
+		&event.LastError,
+		&event.DeadLetteredAt,
+	)
+	return event, err
+}
+
+// eventColumns are the columns scanEvent reads, in order.
+const eventColumns = `id, event_id, event_type, resource_id, payload, created_at, published_at, attempts, last_error, dead_lettered_at`
+
+// eventClaimLease is how long a relay owns the events it claimed. Claims
+// whose relay died are picked up again once the lease runs out.
+const eventClaimLease = 5 * time.Minute
+
+// PublishPendingEvents claims up to limit pending events in id order and
+// hands them to publish one at a time. The claim is committed before
+// publishing starts, so no row lock or transaction is held while the broker
+// is called, and other relays skip claimed events until eventClaimLease runs
+// out. Events accepted by publish are marked as published. The first
+// failure stops the batch and is returned alongside the number of events
+// already published: the failing event's attempts are counted and, once they
+// reach maxAttempts, it is dead-lettered so that it no longer holds up the
+// events behind it. The rest of the batch is released for the next call.
+func (db *DB) PublishPendingEvents(ctx context.Context, limit int, maxAttempts int, publish func(context.Context, Event) error) (int, error) {
+	events, err := db.claimPendingEvents(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+	var published []int64
+	var publishErr error
+	var failed Event
+	for _, event := range events {
+		if publishErr = publish(ctx, event); publishErr != nil {
+			failed = event
+			break
+		}
+		published = append(published, event.ID)
+	}
+	// Record the outcome even if ctx was cancelled mid-batch, so that
+	// delivered events are not published again.
+	ctx = context.WithoutCancel(ctx)
+	err = pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+		if len(published) > 0 {
+			_, err := tx.Exec(ctx, `UPDATE events SET published_at = NOW(), claimed_until = NULL WHERE id = ANY($1)`, published)
+			if err != nil {
+				return err
+			}
+		}
+		if publishErr == nil {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE events
+			SET attempts = attempts + 1,
+				last_error = $2,
+				dead_lettered_at = CASE WHEN attempts + 1 >= $3 THEN NOW() END,
+				claimed_until = NULL
+			WHERE id = $1`, failed.ID, publishErr.Error(), maxAttempts)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE events SET claimed_until = NULL WHERE id = ANY($1) AND published_at IS NULL`, eventIds(events))
+		return err
+	})
+	if err != nil {
+		return 0, mapError(err, nil)
+	}
+	return len(published), publishErr
+}
+
+// claimPendingEvents leases up to limit unpublished, live events that no
+// other relay holds a lease on, lowest id first.
+func (db *DB) claimPendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	query := `
+		UPDATE events
+		SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id
+			FROM events
+			WHERE published_at IS NULL
+				AND dead_lettered_at IS NULL
+				AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + eventColumns
+	rows, err := db.Pool.Query(ctx, query, limit, eventClaimLease.Seconds())
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		return scanEvent(row)
+	})
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	// RETURNING does not keep the subquery's order.
+	slices.SortFunc(events, func(a, b Event) int { return cmp.Compare(a.ID, b.ID) })
+	return events, nil
+}
+
+func eventIds(events []Event) []int64 {
+	ids := make([]int64, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+// committedInIdOrder holds for event rows written by a transaction older
+// than every transaction still running. Ids are handed out at insert rather
+// than at commit, so a transaction holding id N can commit after one holding
+// N+1; a reader that returned N+1 and moved its cursor past it would never
+// see N. Rows younger than the oldest running transaction are left for a
+// later read, by which time every lower id has either committed or rolled
+// back.
+const committedInIdOrder = `tx_id < pg_snapshot_xmin(pg_current_snapshot())`
+
+// StreamEvents calls fn for every event matching filter, in id order, while
+// the rows are still being read so that large replays are not buffered.
+// Events are only returned once no lower id can still commit, so a reader
+// resuming after the last id it saw never skips one; events written by a
+// transaction that is still running, including the caller's own, show up on
+// a later read.
+func (db *DB) StreamEvents(ctx context.Context, filter EventFilter, fn func(Event) error) error {
+	query := `SELECT ` + eventColumns + `
+			  FROM events
+			  WHERE id > $1 AND ` + committedInIdOrder
+	args := []any{filter.AfterID}
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		query += fmt.Sprintf(" AND event_type = $%d", len(args))
+	}
+	if filter.ResourceID != nil {
+		args = append(args, *filter.ResourceID)
+		query += fmt.Sprintf(" AND resource_id = $%d", len(args))
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	rows, err := db.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return mapError(err, nil)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return mapError(rows.Err(), nil)
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"bss/src/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWithinTxRollsBackPlanAndEvent(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+
+	var plan Plan
+	rollback := errors.New("rollback")
+	err := db.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		plan, err = db.CreatePlan(ctx, Plan{
+			Code:         "TEST-TX-" + time.Now().Format("150405.000000"),
+			Name:         "Rolled Back Plan",
+			PriceCents:   499,
+			Currency:     "USD",
+			DurationDays: 30,
+			DataMB:       1024,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		event, err := models.NewEvent(models.PlanCreated{Plan: plan})
+		if err != nil {
+			return err
+		}
+		if err := db.CreateEvent(ctx, event); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Expected the rollback error, got %v", err)
+	}
+
+	if _, err := db.GetPlan(ctx, plan.ID.String()); err == nil {
+		t.Fatalf("Expected plan %s to be rolled back", plan.ID)
+	}
+	var count int
+	err = db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM events WHERE resource_id = $1`, plan.ID).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count events: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Expected no events for rolled back plan, got %d", count)
+	}
+}
+
+func TestStreamEventsByResource(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+
+	plan := Plan{ID: uuid.New()}
+	created, err := models.NewEvent(models.PlanCreated{Plan: plan})
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+	updated, err := models.NewEvent(models.PlanUpdated{Plan: plan})
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+	for _, event := range []Event{created, updated} {
+		if err := db.CreateEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to create event: %v", err)
+		}
+	}
+
+	var events []Event
+	err = db.StreamEvents(ctx, EventFilter{ResourceID: &plan.ID}, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].EventID != created.EventID || events[1].EventID != updated.EventID {
+		t.Fatalf("Unexpected event order: %s, %s", events[0].EventType, events[1].EventType)
+	}
+
+	var after []Event
+	err = db.StreamEvents(ctx, EventFilter{AfterID: events[0].ID, ResourceID: &plan.ID}, func(event Event) error {
+		after = append(after, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream events: %v", err)
+	}
+	if len(after) != 1 || after[0].ID != events[1].ID {
+		t.Fatalf("Expected only event %d after cursor, got %v", events[1].ID, after)
+	}
+}
+
+func TestStreamEventsWaitsForEarlierIds(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+
+	newEvent := func(resourceId uuid.UUID) Event {
+		event, err := models.NewEvent(models.PlanCreated{Plan: Plan{ID: resourceId}})
+		if err != nil {
+			t.Fatalf("Failed to build event: %v", err)
+		}
+		return event
+	}
+	resourceId := uuid.New()
+
+	// slow takes the lower id but commits after fast, which takes the
+	// higher one.
+	slow, err := db.Pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer slow.Rollback(ctx)
+	if err := db.CreateEvent(context.WithValue(ctx, txKey{}, slow), newEvent(resourceId)); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if err := db.CreateEvent(ctx, newEvent(resourceId)); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	stream := func() []Event {
+		var events []Event
+		err := db.StreamEvents(ctx, EventFilter{ResourceID: &resourceId}, func(event Event) error {
+			events = append(events, event)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to stream events: %v", err)
+		}
+		return events
+	}
+	if events := stream(); len(events) != 0 {
+		t.Fatalf("Expected no events while a lower id is uncommitted, got %d", len(events))
+	}
+	if err := slow.Commit(ctx); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	events := stream()
+	if len(events) != 2 || events[0].ID >= events[1].ID {
+		t.Fatalf("Expected both events in id order, got %+v", events)
+	}
+
+	// Publishing the lower event while an unrelated transaction runs must
+	// not hide it again.
+	other, err := db.Pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer other.Rollback(ctx)
+	if _, err := other.Exec(ctx, `SELECT pg_current_xact_id()`); err != nil {
+		t.Fatalf("Failed to assign a transaction id: %v", err)
+	}
+	if _, err := db.Pool.Exec(ctx, `UPDATE events SET published_at = NOW() WHERE id = $1`, events[0].ID); err != nil {
+		t.Fatalf("Failed to publish event: %v", err)
+	}
+	if events := stream(); len(events) != 2 {
+		t.Fatalf("Expected both events after publishing, got %d", len(events))
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const idempotencyColumns = "key, request_hash, completed, status_code, response_headers, response_body, created_at, expires_at"
+
+func scanIdempotencyRecord(row pgx.Row) (IdempotencyRecord, error) {
+	var record IdempotencyRecord
+	var body []byte
+	err := row.Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.Completed,
+		&record.StatusCode,
+		&record.ResponseHeaders,
+		&body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	record.ResponseBody = body
+	return record, err
+}
+
+// ReserveIdempotencyKey claims key for a new request until ttl elapses. An
+// expired record for the same key is replaced. If the key is held by a live
+// record, that record is returned with reserved set to false.
+func (db *DB) ReserveIdempotencyKey(ctx context.Context, key string, requestHash string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	reserveQuery := `
+		INSERT INTO idempotency_keys (key, request_hash, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			completed = false,
+			status_code = 0,
+			response_headers = '{}',
+			response_body = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING ` + idempotencyColumns
+	record, err := scanIdempotencyRecord(db.conn(ctx).QueryRow(ctx, reserveQuery, key, requestHash, time.Now().Add(ttl)))
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return IdempotencyRecord{}, false, mapError(err, nil)
+	}
+	record, err = scanIdempotencyRecord(db.conn(ctx).QueryRow(ctx, `SELECT `+idempotencyColumns+` FROM idempotency_keys WHERE key = $1`, key))
+	if err != nil {
+		return IdempotencyRecord{}, false, mapError(err, nil)
+	}
+	return record, false, nil
+}
+
+// CompleteIdempotencyKey stores the response to replay for a reserved key.
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, headers map[string][]string, body []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET completed = true, status_code = $2, response_headers = $3, response_body = $4
+		WHERE key = $1
+	`
+	_, err := db.conn(ctx).Exec(ctx, query, key, statusCode, headers, body)
+	return mapError(err, nil)
+}
+
+// ReleaseIdempotencyKey drops a reservation so that the request can be retried.
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := db.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND NOT completed`, key)
+	return mapError(err, nil)
+}
+
+// DeleteExpiredIdempotencyKeys removes records whose TTL has passed.
+func (db *DB) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	result, err := db.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, mapError(err, nil)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+func (db *DB) CreateEvent(ctx context.Context, event Event) error {
+	defer db.lock(ctx)()
+	db.state.lastEventID++
+	event.ID = db.state.lastEventID
+	event.CreatedAt = timestamp(event.CreatedAt)
+	db.state.events = append(db.state.events, event)
+	return nil
+}
+
+func (db *DB) StreamEvents(ctx context.Context, filter EventFilter, fn func(Event) error) error {
+	unlock := db.lock(ctx)
+	var matched []Event
+	for _, event := range db.state.events {
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+		if event.ID <= filter.AfterID ||
+			(filter.EventType != "" && event.EventType != filter.EventType) ||
+			(filter.ResourceID != nil && event.ResourceID != *filter.ResourceID) {
+			continue
+		}
+		matched = append(matched, event)
+	}
+	unlock()
+	for _, event := range matched {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventClaimLease mirrors the lease database.DB puts on claimed events.
+const eventClaimLease = 5 * time.Minute
+
+// PublishPendingEvents claims pending events under the lock, publishes them
+// without it, and then takes the lock again to record the outcome, like
+// database.DB does with its claim lease.
+func (db *DB) PublishPendingEvents(ctx context.Context, limit int, maxAttempts int, publish func(context.Context, Event) error) (int, error) {
+	events := db.claimPendingEvents(ctx, limit)
+	published := map[int64]bool{}
+	var publishErr error
+	var failed int64
+	for _, event := range events {
+		if publishErr = publish(ctx, event); publishErr != nil {
+			failed = event.ID
+			break
+		}
+		published[event.ID] = true
+	}
+
+	defer db.lock(ctx)()
+	now := timestamp(db.now())
+	for _, claimed := range events {
+		delete(db.state.eventClaims, claimed.ID)
+		event := db.event(claimed.ID)
+		switch {
+		case published[event.ID]:
+			event.PublishedAt = &now
+		case event.ID == failed:
+			message := publishErr.Error()
+			event.Attempts++
+			event.LastError = &message
+			if event.Attempts >= maxAttempts {
+				event.DeadLetteredAt = &now
+			}
+		}
+	}
+	return len(published), publishErr
+}
+
+// claimPendingEvents leases up to limit unpublished, live events that no
+// other relay holds a lease on, lowest id first.
+func (db *DB) claimPendingEvents(ctx context.Context, limit int) []Event {
+	defer db.lock(ctx)()
+	now := db.now()
+	var claimed []Event
+	for _, event := range db.state.events {
+		if len(claimed) == limit {
+			break
+		}
+		if event.PublishedAt != nil || event.DeadLetteredAt != nil {
+			continue
+		}
+		if until, ok := db.state.eventClaims[event.ID]; ok && !until.Before(now) {
+			continue
+		}
+		db.state.eventClaims[event.ID] = now.Add(eventClaimLease)
+		claimed = append(claimed, event)
+	}
+	return claimed
+}
+
+// event returns the stored event with the given id, which must exist.
+func (db *DB) event(id int64) *Event {
+	for i := range db.state.events {
+		if db.state.events[i].ID == id {
+			return &db.state.events[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+func (db *DB) ReserveIdempotencyKey(ctx context.Context, key string, requestHash string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	defer db.lock(ctx)()
+	now := db.now()
+	if record, ok := db.state.idempotency[key]; ok && record.ExpiresAt.After(now) {
+		return record, false, nil
+	}
+	record := IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   timestamp(now),
+		ExpiresAt:   timestamp(now.Add(ttl)),
+	}
+	db.state.idempotency[key] = record
+	return record, true, nil
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, headers map[string][]string, body []byte) error {
+	defer db.lock(ctx)()
+	record, ok := db.state.idempotency[key]
+	if !ok {
+		return nil
+	}
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseHeaders = maps.Clone(headers)
+	record.ResponseBody = append([]byte(nil), body...)
+	db.state.idempotency[key] = record
+	return nil
+}
+
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	defer db.lock(ctx)()
+	if record, ok := db.state.idempotency[key]; ok && !record.Completed {
+		delete(db.state.idempotency, key)
+	}
+	return nil
+}
+
+func (db *DB) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	defer db.lock(ctx)()
+	var deleted int64
+	for key, record := range db.state.idempotency {
+		if !record.ExpiresAt.After(now) {
+			delete(db.state.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,170 @@
+// Package memory is an in-memory implementation of the database used by the
+// server, for tests and local runs without Postgres. It mirrors the
+// behaviour of database.DB, including its apperrors, and is kept honest by
+// the shared suite in package databasetest.
+package memory
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PageableRequest = models.PageableRequest
+type SortField = models.SortField
+type Page[V any] = models.Page[V]
+type Plan = models.Plan
+type PlanVersion = models.PlanVersion
+type PlanFilter = models.PlanFilter
+type Subscription = models.Subscription
+type PlanSubscriptionCount = models.PlanSubscriptionCount
+type SubscriptionStatus = models.SubscriptionStatus
+type Event = models.Event
+type EventFilter = models.EventFilter
+type IdempotencyRecord = models.IdempotencyRecord
+
+type state struct {
+	plans         map[uuid.UUID]Plan
+	planVersions  map[uuid.UUID][]PlanVersion
+	subscriptions map[uuid.UUID]Subscription
+	events        []Event
+	idempotency   map[string]IdempotencyRecord
+	lastEventID   int64
+	// eventClaims holds, by event id, until when a relay owns the events it
+	// is publishing.
+	eventClaims map[int64]time.Time
+}
+
+func newState() *state {
+	return &state{
+		plans:         map[uuid.UUID]Plan{},
+		planVersions:  map[uuid.UUID][]PlanVersion{},
+		subscriptions: map[uuid.UUID]Subscription{},
+		idempotency:   map[string]IdempotencyRecord{},
+		eventClaims:   map[int64]time.Time{},
+	}
+}
+
+func (s *state) clone() *state {
+	c := newState()
+	for id, plan := range s.plans {
+		c.plans[id] = plan
+	}
+	for id, versions := range s.planVersions {
+		// Versions are only ever appended, so sharing the backing array
+		// is safe as long as the clone's slice is clipped.
+		c.planVersions[id] = slices.Clip(versions)
+	}
+	for id, subscription := range s.subscriptions {
+		c.subscriptions[id] = subscription
+	}
+	for key, record := range s.idempotency {
+		c.idempotency[key] = record
+	}
+	for id, until := range s.eventClaims {
+		c.eventClaims[id] = until
+	}
+	c.events = append([]Event(nil), s.events...)
+	c.lastEventID = s.lastEventID
+	return c
+}
+
+// DB holds all data in memory behind a single mutex. A transaction started
+// by WithinTx holds that mutex until it ends, so transactions are fully
+// serialized, and rolls back by restoring a snapshot taken when it began.
+type DB struct {
+	mu    sync.Mutex
+	state *state
+	now   func() time.Time
+}
+
+func New() *DB {
+	return &DB{state: newState(), now: time.Now}
+}
+
+type txKey struct{}
+
+// lock takes the mutex unless ctx belongs to a transaction of db, which
+// already holds it. The returned func releases whatever was taken.
+func (db *DB) lock(ctx context.Context) func() {
+	if ctx.Value(txKey{}) == db {
+		return func() {}
+	}
+	db.mu.Lock()
+	return db.mu.Unlock
+}
+
+func (db *DB) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == db {
+		return fn(ctx)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	snapshot := db.state.clone()
+	if err := fn(context.WithValue(ctx, txKey{}, db)); err != nil {
+		db.state = snapshot
+		return err
+	}
+	return nil
+}
+
+// timestamp mimics Postgres' microsecond timestamp precision.
+func timestamp(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (db *DB) Close() {}
+
+type keyed interface {
+	Cursor() models.Cursor
+}
+
+// paginate sorts items with compare and returns the requested page. Cursors
+// are only valid, and NextCursor only set, when compare is newestFirst.
+func paginate[V keyed](items []V, compare func(a, b V) int, pageableRequest PageableRequest, keyset bool) (Page[V], error) {
+	slices.SortStableFunc(items, compare)
+	if pageableRequest.Cursor == "" {
+		totalCount := int64(len(items))
+		offset := min(max((pageableRequest.Page-1)*pageableRequest.PageSize, 0), len(items))
+		page := pageOf(items[offset:], pageableRequest.PageSize, keyset)
+		page.TotalCount = &totalCount
+		return page, nil
+	}
+	if !keyset {
+		return Page[V]{}, apperrors.Validation("cursor cannot be combined with sort")
+	}
+	cursor, err := models.DecodeCursor(pageableRequest.Cursor)
+	if err != nil {
+		return Page[V]{}, apperrors.Validation("invalid cursor")
+	}
+	start := 0
+	for start < len(items) && items[start].Cursor().Compare(cursor) >= 0 {
+		start++
+	}
+	return pageOf(items[start:], pageableRequest.PageSize, keyset), nil
+}
+
+// pageOf takes the first pageSize of items, pointing NextCursor past them
+// when more follow.
+func pageOf[V keyed](items []V, pageSize int, keyset bool) Page[V] {
+	// Like pgx.CollectRows, an empty page has an empty, not nil, slice.
+	page := Page[V]{Items: append([]V{}, items[:min(pageSize, len(items))]...)}
+	if keyset && len(items) > pageSize && pageSize > 0 {
+		page.NextCursor = page.Items[pageSize-1].Cursor().Encode()
+	}
+	return page
+}
+
+// newestFirst orders like ORDER BY created_at DESC, id DESC.
+func newestFirst[V keyed](a, b V) int {
+	return b.Cursor().Compare(a.Cursor())
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"bss/src/database/databasetest"
+	"bss/src/models"
+	"bss/src/server"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) server.Database {
+		return New()
+	})
+}
+
+func TestConformanceSweeps(t *testing.T) {
+	databasetest.RunSweeps(t, func(t *testing.T) server.Database {
+		return New()
+	})
+}
+
+func TestPublishPendingEventsReleasesLock(t *testing.T) {
+	db := New()
+	ctx := context.Background()
+	event, err := models.NewEvent(models.PlanCreated{Plan: models.Plan{ID: uuid.New()}})
+	if err != nil {
+		t.Fatalf("Failed to build event: %v", err)
+	}
+	if err := db.CreateEvent(ctx, event); err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := db.PublishPendingEvents(ctx, 10, 3, func(ctx context.Context, event models.Event) error {
+			// Deadlocks if publish is called with the mutex held.
+			_, err := db.GetPlans(ctx, models.PlanFilter{}, models.PageableRequest{Page: 1, PageSize: 1})
+			return err
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to publish events: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PublishPendingEvents held the lock while publishing")
+	}
+}
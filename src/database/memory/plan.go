@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"bss/src/apperrors"
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) codeTaken(code string, except uuid.UUID) bool {
+	for id, plan := range db.state.plans {
+		if plan.Code == code && id != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) CreatePlan(ctx context.Context, plan Plan) (Plan, error) {
+	defer db.lock(ctx)()
+	if db.codeTaken(plan.Code, uuid.Nil) {
+		return Plan{}, apperrors.ErrPlanAlreadyExists
+	}
+	plan.ID = uuid.New()
+	// Like the INSERT in database.CreatePlan, new plans always start active.
+	plan.Active = true
+	plan.Version = 1
+	plan.CreatedAt = timestamp(plan.CreatedAt)
+	plan.UpdatedAt = timestamp(plan.UpdatedAt)
+	db.state.plans[plan.ID] = plan
+	db.state.planVersions[plan.ID] = []PlanVersion{plan.CurrentVersion()}
+	return plan, nil
+}
+
+// planSortFields mirrors the whitelist in database.GetPlans, which sorts text
+// by byte value like strings.Compare.
+var planSortFields = map[string]func(a, b Plan) int{
+	"code":          func(a, b Plan) int { return strings.Compare(a.Code, b.Code) },
+	"name":          func(a, b Plan) int { return strings.Compare(a.Name, b.Name) },
+	"price_cents":   func(a, b Plan) int { return cmp.Compare(a.PriceCents, b.PriceCents) },
+	"currency":      func(a, b Plan) int { return strings.Compare(a.Currency, b.Currency) },
+	"duration_days": func(a, b Plan) int { return cmp.Compare(a.DurationDays, b.DurationDays) },
+	"data_mb":       func(a, b Plan) int { return cmp.Compare(a.DataMB, b.DataMB) },
+	"created_at":    func(a, b Plan) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at":    func(a, b Plan) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+func planCompare(sort []SortField) (func(a, b Plan) int, error) {
+	if len(sort) == 0 {
+		return newestFirst, nil
+	}
+	compares := make([]func(a, b Plan) int, len(sort))
+	for i, field := range sort {
+		compare, ok := planSortFields[field.Field]
+		if !ok {
+			return nil, apperrors.Validation("unsupported sort field %q", field.Field)
+		}
+		if field.Descending {
+			compare = func(a, b Plan) int { return planSortFields[field.Field](b, a) }
+		}
+		compares[i] = compare
+	}
+	return func(a, b Plan) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	}, nil
+}
+
+func planMatches(plan Plan, filter PlanFilter) bool {
+	switch {
+	case filter.Active != nil && plan.Active != *filter.Active,
+		filter.Currency != "" && plan.Currency != filter.Currency,
+		filter.MinPriceCents != nil && plan.PriceCents < *filter.MinPriceCents,
+		filter.MaxPriceCents != nil && plan.PriceCents > *filter.MaxPriceCents,
+		filter.MinDurationDays != nil && plan.DurationDays < *filter.MinDurationDays,
+		filter.MaxDurationDays != nil && plan.DurationDays > *filter.MaxDurationDays,
+		!strings.HasPrefix(plan.Code, filter.CodePrefix),
+		!strings.Contains(strings.ToLower(plan.Name), strings.ToLower(filter.Name)):
+		return false
+	}
+	return true
+}
+
+func (db *DB) GetPlans(ctx context.Context, filter PlanFilter, pageableRequest PageableRequest) (Page[Plan], error) {
+	compare, err := planCompare(filter.Sort)
+	if err != nil {
+		return Page[Plan]{}, err
+	}
+	defer db.lock(ctx)()
+	plans := make([]Plan, 0, len(db.state.plans))
+	for _, plan := range db.state.plans {
+		if planMatches(plan, filter) {
+			plans = append(plans, plan)
+		}
+	}
+	return paginate(plans, compare, pageableRequest, len(filter.Sort) == 0)
+}
+
+func (db *DB) GetPlan(ctx context.Context, id string) (Plan, error) {
+	defer db.lock(ctx)()
+	planId, err := uuid.Parse(id)
+	if err != nil {
+		return Plan{}, apperrors.ErrPlanNotFound
+	}
+	plan, ok := db.state.plans[planId]
+	if !ok {
+		return Plan{}, apperrors.ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+func (db *DB) GetPlanByCode(ctx context.Context, code string) (Plan, error) {
+	defer db.lock(ctx)()
+	for _, plan := range db.state.plans {
+		if plan.Code == code {
+			return plan, nil
+		}
+	}
+	return Plan{}, apperrors.ErrPlanNotFound
+}
+
+func (db *DB) UpdatePlan(ctx context.Context, plan Plan) (Plan, error) {
+	defer db.lock(ctx)()
+	existing, ok := db.state.plans[plan.ID]
+	if !ok {
+		return Plan{}, apperrors.ErrPlanNotFound
+	}
+	if plan.Version != 0 && plan.Version != existing.Version {
+		return Plan{}, apperrors.ErrPlanModified
+	}
+	if db.codeTaken(plan.Code, plan.ID) {
+		return Plan{}, apperrors.ErrPlanAlreadyExists
+	}
+	plan.CreatedAt = existing.CreatedAt
+	plan.UpdatedAt = timestamp(plan.UpdatedAt)
+	plan.Version = existing.Version + 1
+	plan.RetiredAt = existing.RetiredAt
+	plan.SuccessorPlanID = existing.SuccessorPlanID
+	db.state.plans[plan.ID] = plan
+	db.state.planVersions[plan.ID] = append(db.state.planVersions[plan.ID], plan.CurrentVersion())
+	return plan, nil
+}
+
+func (db *DB) RetirePlan(ctx context.Context, plan Plan) (Plan, error) {
+	defer db.lock(ctx)()
+	existing, ok := db.state.plans[plan.ID]
+	if !ok {
+		return Plan{}, apperrors.ErrPlanNotFound
+	}
+	if plan.RetiredAt != nil {
+		retiredAt := timestamp(*plan.RetiredAt)
+		existing.RetiredAt = &retiredAt
+	} else {
+		existing.RetiredAt = nil
+	}
+	existing.SuccessorPlanID = plan.SuccessorPlanID
+	existing.UpdatedAt = timestamp(plan.UpdatedAt)
+	db.state.plans[plan.ID] = existing
+	return existing, nil
+}
+
+func (db *DB) GetPlanVersions(ctx context.Context, planId string) ([]PlanVersion, error) {
+	defer db.lock(ctx)()
+	id, err := uuid.Parse(planId)
+	if err != nil {
+		return nil, apperrors.ErrPlanNotFound
+	}
+	versions := slices.Clone(db.state.planVersions[id])
+	if len(versions) == 0 {
+		return nil, apperrors.ErrPlanNotFound
+	}
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+func (db *DB) GetPlanVersion(ctx context.Context, planId string, version int) (PlanVersion, error) {
+	defer db.lock(ctx)()
+	id, err := uuid.Parse(planId)
+	if err != nil {
+		return PlanVersion{}, apperrors.ErrPlanNotFound
+	}
+	for _, planVersion := range db.state.planVersions[id] {
+		if planVersion.Version == version {
+			return planVersion, nil
+		}
+	}
+	return PlanVersion{}, apperrors.ErrPlanNotFound
+}
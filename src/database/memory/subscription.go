@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (db *DB) CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	defer db.lock(ctx)()
+	plan, ok := db.state.plans[subscription.PlanID]
+	if !ok {
+		return Subscription{}, apperrors.ErrPlanNotFound
+	}
+	if subscription.PlanVersion == 0 {
+		subscription.PlanVersion = plan.Version
+	}
+	if subscription.PlanVersion < 1 || subscription.PlanVersion > plan.Version {
+		return Subscription{}, apperrors.ErrPlanNotFound
+	}
+	for _, existing := range db.state.subscriptions {
+		if subscription.RenewedFrom != nil && existing.RenewedFrom != nil && *existing.RenewedFrom == *subscription.RenewedFrom {
+			return Subscription{}, apperrors.ErrSubscriptionAlreadyRenewed
+		}
+	}
+	if subscription.Status == models.SubscriptionStatusActive {
+		for _, existing := range db.state.subscriptions {
+			if existing.CustomerID == subscription.CustomerID && existing.Status == models.SubscriptionStatusActive {
+				return Subscription{}, apperrors.ErrSubscriptionAlreadyExists
+			}
+		}
+	}
+	subscription.ID = uuid.New()
+	subscription.StartDate = timestamp(subscription.StartDate)
+	subscription.EndDate = timestamp(subscription.EndDate)
+	subscription.CreatedAt = timestamp(subscription.CreatedAt)
+	subscription.UpdatedAt = timestamp(subscription.UpdatedAt)
+	db.state.subscriptions[subscription.ID] = subscription
+	return subscription, nil
+}
+
+func (db *DB) GetSubscriptionsByUserId(ctx context.Context, pageableRequest PageableRequest, userId string) (Page[Subscription], error) {
+	defer db.lock(ctx)()
+	var subscriptions []Subscription
+	for _, subscription := range db.state.subscriptions {
+		if subscription.CustomerID.String() == userId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return paginate(subscriptions, newestFirst, pageableRequest, true)
+}
+
+func (db *DB) GetActiveSubscriptionByUserId(ctx context.Context, userId string) (Subscription, error) {
+	defer db.lock(ctx)()
+	for _, subscription := range db.state.subscriptions {
+		if subscription.CustomerID.String() == userId && subscription.Status == models.SubscriptionStatusActive {
+			return subscription, nil
+		}
+	}
+	return Subscription{}, apperrors.ErrSubscriptionNotFound
+}
+
+func (db *DB) CountActiveSubscriptionsByPlan(ctx context.Context) ([]PlanSubscriptionCount, error) {
+	defer db.lock(ctx)()
+	active := map[uuid.UUID]int64{}
+	for _, subscription := range db.state.subscriptions {
+		if subscription.Status == models.SubscriptionStatusActive {
+			active[subscription.PlanID]++
+		}
+	}
+	counts := make([]PlanSubscriptionCount, 0, len(db.state.plans))
+	for id, plan := range db.state.plans {
+		counts = append(counts, PlanSubscriptionCount{PlanID: id, PlanCode: plan.Code, Active: active[id]})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].PlanCode < counts[j].PlanCode
+	})
+	return counts, nil
+}
+
+func (db *DB) setStatus(subscription Subscription, status SubscriptionStatus) Subscription {
+	subscription.Status = status
+	subscription.UpdatedAt = timestamp(db.now())
+	db.state.subscriptions[subscription.ID] = subscription
+	return subscription
+}
+
+func (db *DB) CancelSubscription(ctx context.Context, subscriptionId string, customerId string) (Subscription, error) {
+	defer db.lock(ctx)()
+	for id, subscription := range db.state.subscriptions {
+		if id.String() == subscriptionId && subscription.CustomerID.String() == customerId && subscription.Status == models.SubscriptionStatusActive {
+			return db.setStatus(subscription, models.SubscriptionStatusCancelled), nil
+		}
+	}
+	return Subscription{}, apperrors.ErrSubscriptionNotFound
+}
+
+func (db *DB) UpdateSubscriptionStatus(ctx context.Context, id string, status SubscriptionStatus) (Subscription, error) {
+	defer db.lock(ctx)()
+	subscriptionId, err := uuid.Parse(id)
+	if err != nil {
+		return Subscription{}, apperrors.ErrSubscriptionNotFound
+	}
+	subscription, ok := db.state.subscriptions[subscriptionId]
+	if !ok {
+		return Subscription{}, apperrors.ErrSubscriptionNotFound
+	}
+	return db.setStatus(subscription, status), nil
+}
+
+// due returns up to limit ACTIVE subscriptions ending at or before now that
+// match keep, earliest end date first.
+func (db *DB) due(now time.Time, limit int, keep func(Subscription) bool) []Subscription {
+	var due []Subscription
+	for _, subscription := range db.state.subscriptions {
+		if subscription.Status == models.SubscriptionStatusActive && !subscription.EndDate.After(now) && keep(subscription) {
+			due = append(due, subscription)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].EndDate.Before(due[j].EndDate) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due
+}
+
+// renewable mirrors the renewablePlan condition of database.LockDueRenewals.
+func (db *DB) renewable(subscription Subscription) bool {
+	plan := db.state.plans[subscription.PlanID]
+	return subscription.AutoRenew && plan.Active && (!plan.RetiredBy(subscription.EndDate) || plan.SuccessorPlanID != nil)
+}
+
+func (db *DB) ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	defer db.lock(ctx)()
+	due := db.due(now, limit, func(s Subscription) bool { return !db.renewable(s) })
+	expired := make([]Subscription, 0, len(due))
+	for _, subscription := range due {
+		expired = append(expired, db.setStatus(subscription, models.SubscriptionStatusExpired))
+	}
+	return expired, nil
+}
+
+// LockDueRenewals returns the due renewals. Rows need no locking of their
+// own: the transaction it is called in already excludes everyone else.
+func (db *DB) LockDueRenewals(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	defer db.lock(ctx)()
+	return db.due(now, limit, db.renewable), nil
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Migration is one versioned schema change. Up applies it and Down reverts
+// it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationState is a known migration and when it was applied, if it was.
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockKey is the key of the advisory lock that serializes
+// migration runs against the same database.
+const migrationLockKey int64 = 0x6273735f6d6967 // "bss_mig"
+
+const undefinedTable = "42P01"
+
+const createSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`
+
+// Migrations returns the migrations embedded in the binary, oldest first.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations")
+}
+
+// loadMigrations reads NNNN_name.up.sql and NNNN_name.down.sql pairs from
+// dir. Versions must be numbered 1, 2, 3... without gaps, and every version
+// needs both files.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		data, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		migration, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is missing", version)
+		}
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	return migrations, nil
+}
+
+// appliedMigrations returns when each applied version was applied. A
+// database that was never migrated has no schema_migrations table and no
+// applied versions.
+func appliedMigrations(ctx context.Context, q querier) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	rows, err := q.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err == nil {
+		var version int
+		var appliedAt time.Time
+		_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+			applied[version] = appliedAt
+			return nil
+		})
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedTable {
+		return map[int]time.Time{}, nil
+	}
+	return applied, err
+}
+
+// withMigrationLock runs fn on a connection holding the migration advisory
+// lock, so that migrations started concurrently, for instance by several
+// replicas rolling out at once, run one after the other.
+func (db *DB) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	if _, err := conn.Exec(ctx, createSchemaMigrations); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// runMigration executes sql and updates schema_migrations with record in
+// one transaction, so a failed migration leaves no trace.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record string, args ...any) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, record, args...)
+		return err
+	})
+}
+
+// MigrateUp applies every pending migration, oldest first, and returns the
+// ones it applied.
+func (db *DB) MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = db.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := runMigration(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %s failed: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the steps most recently applied migrations, newest
+// first, and returns the ones it reverted.
+func (db *DB) MigrateDown(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("cannot revert %d migrations", steps)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	err = db.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		slices.Sort(versions)
+		slices.Reverse(versions)
+		for _, version := range versions[:min(steps, len(versions))] {
+			if version > len(migrations) {
+				return fmt.Errorf("migration %d is not known to this binary", version)
+			}
+			migration := migrations[version-1]
+			err := runMigration(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+			if err != nil {
+				return fmt.Errorf("reverting migration %s failed: %w", migration, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus lists every migration embedded in the binary along with
+// when it was applied.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db.Pool)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i].Migration = migration
+		if appliedAt, ok := applied[migration.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// CheckMigrations reports an error unless every migration embedded in the
+// binary has been applied, i.e. unless the schema is the one the code
+// expects.
+func (db *DB) CheckMigrations(ctx context.Context) error {
+	states, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+	var pending int
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d of %d migrations pending", pending, len(states))
+	}
+	return nil
+}
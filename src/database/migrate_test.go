@@ -0,0 +1,123 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("Expected embedded migrations")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("Expected migration %d at position %d, got %s", i+1, i, migration)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(sql string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(sql)}
+	}
+	testCases := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "Ordered",
+			files: fstest.MapFS{
+				"m/0002_add_b.up.sql":   file("CREATE TABLE b ()"),
+				"m/0002_add_b.down.sql": file("DROP TABLE b"),
+				"m/0001_add_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_add_a.down.sql": file("DROP TABLE a"),
+			},
+			want: []string{"0001_add_a", "0002_add_b"},
+		},
+		{
+			name:  "Empty",
+			files: fstest.MapFS{"m": &fstest.MapFile{Mode: 0o755 | 1<<31}},
+		},
+		{
+			name: "MissingDown",
+			files: fstest.MapFS{
+				"m/0001_add_a.up.sql": file("CREATE TABLE a ()"),
+			},
+			wantErr: "needs both",
+		},
+		{
+			name: "Gap",
+			files: fstest.MapFS{
+				"m/0001_add_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_add_a.down.sql": file("DROP TABLE a"),
+				"m/0003_add_c.up.sql":   file("CREATE TABLE c ()"),
+				"m/0003_add_c.down.sql": file("DROP TABLE c"),
+			},
+			wantErr: "missing",
+		},
+		{
+			name: "NameMismatch",
+			files: fstest.MapFS{
+				"m/0001_add_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_add_b.down.sql": file("DROP TABLE a"),
+			},
+			wantErr: "named both",
+		},
+		{
+			name: "UnexpectedFile",
+			files: fstest.MapFS{
+				"m/README.md": file("notes"),
+			},
+			wantErr: "unexpected",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tc.files, "m")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(migrations) != len(tc.want) {
+				t.Fatalf("Expected %v, got %v", tc.want, migrations)
+			}
+			for i, migration := range migrations {
+				if migration.String() != tc.want[i] || migration.Up == "" || migration.Down == "" {
+					t.Errorf("Expected %s with both directions, got %+v", tc.want[i], migration)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateUpIsIdempotent(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	applied, err := db.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("Failed to migrate again: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected nothing left to apply, got %v", applied)
+	}
+	if err := db.CheckMigrations(ctx); err != nil {
+		t.Errorf("Expected every migration to be applied: %v", err)
+	}
+}
@@ -1,4 +1,6 @@
-\c bss;
+-- The schema as it was before migrations were introduced, so that databases
+-- created from the old docker init script can adopt them unchanged.
+
 -- Plans table
 CREATE TABLE IF NOT EXISTS plans (
 	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -13,19 +15,6 @@ CREATE TABLE IF NOT EXISTS plans (
 	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
 
--- Insert sample plan
-INSERT INTO plans (id, code, name, price_cents, currency, duration_days, data_mb, active)
-VALUES (
-    '11111111-1111-1111-1111-111111111111',
-    'BASIC-MONTHLY',
-    'Basic Monthly Plan',
-    999,
-    'USD',
-    30,
-    5120,
-    true
-);
-
 -- Subscriptions table
 CREATE TABLE IF NOT EXISTS subscriptions (
 	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -39,36 +28,6 @@ CREATE TABLE IF NOT EXISTS subscriptions (
 	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
 );
 
--- Insert sample subscription
-INSERT INTO subscriptions (id, customer_id, plan_id, start_date, end_date, status, auto_renew)
-VALUES (
-    '22222222-2222-2222-2222-222222222222',
-    '00000000-0000-0000-0000-000000000000',
-    '11111111-1111-1111-1111-111111111111',
-    NOW() - INTERVAL '1 days',
-    NOW() + INTERVAL '30 days',
-    'ACTIVE',
-    true
-),
-(
-    gen_random_uuid(),
-    '00000000-0000-0000-0000-000000000000',
-    '11111111-1111-1111-1111-111111111111',
-    NOW() - INTERVAL '30 days',
-    NOW() - INTERVAL '2 days',
-    'EXPIRED',
-    true
-),
-(
-    gen_random_uuid(),
-    '00000000-0000-0000-0000-000000000001',
-    '11111111-1111-1111-1111-111111111111',
-    NOW() - INTERVAL '32 days',
-    NOW() - INTERVAL '4 days',
-    'EXPIRED',
-    true
-);
-
 -- Events table
 CREATE TABLE IF NOT EXISTS events (
 	id BIGSERIAL PRIMARY KEY,
@@ -84,4 +43,4 @@ CREATE INDEX IF NOT EXISTS idx_subscriptions_plan_id ON subscriptions(plan_id);
 CREATE INDEX IF NOT EXISTS idx_subscriptions_status ON subscriptions(status);
 CREATE INDEX IF NOT EXISTS idx_events_resource_id ON events(resource_id);
 CREATE INDEX IF NOT EXISTS idx_events_event_type ON events(event_type);
-CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
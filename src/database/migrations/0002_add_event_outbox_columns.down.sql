@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_events_unpublished;
+ALTER TABLE events DROP COLUMN IF EXISTS published_at;
+ALTER TABLE events DROP COLUMN IF EXISTS event_id;
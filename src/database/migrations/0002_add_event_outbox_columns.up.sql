@@ -0,0 +1,5 @@
+-- Transactional outbox, drained by the relay.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS event_id UUID UNIQUE NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_events_unpublished ON events(id) WHERE published_at IS NULL;
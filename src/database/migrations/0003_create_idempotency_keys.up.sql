@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key VARCHAR(255) PRIMARY KEY,
+	request_hash VARCHAR(64) NOT NULL,
+	completed BOOLEAN NOT NULL DEFAULT false,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	content_type VARCHAR(255) NOT NULL DEFAULT '',
+	response_body BYTEA,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_plan_version_fkey;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS plan_version;
+DROP TABLE IF EXISTS plan_versions;
+ALTER TABLE plans DROP COLUMN IF EXISTS version;
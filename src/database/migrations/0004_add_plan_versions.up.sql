@@ -0,0 +1,25 @@
+-- One immutable row per version of a plan's terms. Existing plans and
+-- subscriptions start out on version 1.
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS plan_versions (
+	plan_id UUID NOT NULL REFERENCES plans(id),
+	version INTEGER NOT NULL,
+	code VARCHAR(50) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	price_cents BIGINT NOT NULL,
+	currency VARCHAR(3) NOT NULL,
+	duration_days INTEGER NOT NULL,
+	data_mb BIGINT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (plan_id, version)
+);
+
+INSERT INTO plan_versions (plan_id, version, code, name, price_cents, currency, duration_days, data_mb, created_at)
+SELECT id, version, code, name, price_cents, currency, duration_days, data_mb, updated_at FROM plans
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS plan_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_plan_version_fkey;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_plan_version_fkey
+	FOREIGN KEY (plan_id, plan_version) REFERENCES plan_versions(plan_id, version);
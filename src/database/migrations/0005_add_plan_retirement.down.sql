@@ -0,0 +1,2 @@
+ALTER TABLE plans DROP COLUMN IF EXISTS successor_plan_id;
+ALTER TABLE plans DROP COLUMN IF EXISTS retired_at;
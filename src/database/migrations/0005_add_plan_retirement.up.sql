@@ -0,0 +1,2 @@
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS successor_plan_id UUID REFERENCES plans(id);
@@ -0,0 +1,4 @@
+-- The transaction that wrote each event, so readers can wait for every
+-- lower id to commit. xmin cannot serve: it changes whenever the relay
+-- updates the row.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS tx_id XID8 NOT NULL DEFAULT pg_current_xact_id();
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_events_dead_lettered;
+DROP INDEX IF EXISTS idx_events_pending;
+CREATE INDEX IF NOT EXISTS idx_events_unpublished ON events(id) WHERE published_at IS NULL;
+
+ALTER TABLE events DROP COLUMN IF EXISTS claimed_until;
+ALTER TABLE events DROP COLUMN IF EXISTS dead_lettered_at;
+ALTER TABLE events DROP COLUMN IF EXISTS last_error;
+ALTER TABLE events DROP COLUMN IF EXISTS attempts;
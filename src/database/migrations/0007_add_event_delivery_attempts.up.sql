@@ -0,0 +1,12 @@
+-- Delivery bookkeeping for the outbox relay. Events are claimed for a short
+-- lease instead of being locked while they are published, and an event the
+-- broker keeps rejecting is dead-lettered after too many attempts so that it
+-- stops blocking the events behind it.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP WITH TIME ZONE;
+
+DROP INDEX IF EXISTS idx_events_unpublished;
+CREATE INDEX IF NOT EXISTS idx_events_pending ON events(id) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_events_dead_lettered ON events(id) WHERE dead_lettered_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS content_type VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS response_headers;
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE VARCHAR(255);
@@ -0,0 +1,7 @@
+-- Stored responses keep their headers, such as ETag and Location, and keys
+-- are scoped to the method and path they were sent to. Records stored under
+-- the old unscoped keys can never match again, so they are dropped.
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE TEXT;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS response_headers JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS content_type;
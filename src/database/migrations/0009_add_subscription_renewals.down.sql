@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_active_end_date;
+DROP INDEX IF EXISTS idx_subscriptions_one_active_per_customer;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS renewed_from;
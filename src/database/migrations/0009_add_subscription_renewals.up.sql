@@ -0,0 +1,8 @@
+-- A renewal points at the subscription it continues, and each subscription
+-- is renewed at most once. A customer may hold only one active subscription;
+-- creating the index fails while some customer still holds several, which
+-- have to be cancelled first.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS renewed_from UUID UNIQUE REFERENCES subscriptions(id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_one_active_per_customer ON subscriptions(customer_id) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS idx_subscriptions_active_end_date ON subscriptions(end_date) WHERE status = 'ACTIVE';
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_customer_id ON subscriptions(customer_id);
+DROP INDEX IF EXISTS idx_subscriptions_customer_created_at_id;
+DROP INDEX IF EXISTS idx_plans_created_at_id;
@@ -0,0 +1,5 @@
+-- Cursor pagination walks (created_at, id) newest first. The customer index
+-- also serves lookups by customer alone, so it replaces the old one.
+CREATE INDEX IF NOT EXISTS idx_plans_created_at_id ON plans(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_customer_created_at_id ON subscriptions(customer_id, created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_subscriptions_customer_id;
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"fmt"
+)
+
+// keysetOrder is the order cursors continue in.
+const keysetOrder = " ORDER BY created_at DESC, id DESC"
+
+// keysetCondition restricts rows to those after the request's cursor, if
+// any, appending its arguments.
+func keysetCondition(pageableRequest PageableRequest, args []any) (string, []any, error) {
+	if pageableRequest.Cursor == "" {
+		return "", args, nil
+	}
+	cursor, err := models.DecodeCursor(pageableRequest.Cursor)
+	if err != nil {
+		return "", args, apperrors.Validation("invalid cursor")
+	}
+	args = append(args, cursor.CreatedAt, cursor.ID)
+	return fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)), args, nil
+}
+
+// limitOffset returns the LIMIT and, for numbered pages, OFFSET clause. One
+// row more than the page size is fetched so newPage can tell whether another
+// page follows.
+func limitOffset(pageableRequest PageableRequest, args []any) (string, []any) {
+	args = append(args, pageableRequest.PageSize+1)
+	clause := fmt.Sprintf(" LIMIT $%d", len(args))
+	if pageableRequest.Cursor == "" {
+		args = append(args, (pageableRequest.Page-1)*pageableRequest.PageSize)
+		clause += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	return clause, args
+}
+
+// newPage trims the extra row fetched by limitOffset. keyed reports whether
+// items are in keysetOrder, in which case NextCursor is set when more rows
+// follow.
+func newPage[V interface{ Cursor() models.Cursor }](items []V, pageableRequest PageableRequest, keyed bool) Page[V] {
+	page := Page[V]{Items: items}
+	if len(items) > pageableRequest.PageSize {
+		page.Items = items[:pageableRequest.PageSize]
+		if keyed && len(page.Items) > 0 {
+			page.NextCursor = page.Items[len(page.Items)-1].Cursor().Encode()
+		}
+	}
+	return page
+}
@@ -1,17 +1,32 @@
 package database
 
 import (
+	"bss/src/apperrors"
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+// planVersionInsert records the plan row returned by the CTE p as a new
+// plan_versions row, so a plan and its version are written by one statement.
+const planVersionInsert = `
+	v AS (
+		INSERT INTO plan_versions (plan_id, version, code, name, price_cents, currency, duration_days, data_mb, created_at)
+		SELECT id, version, code, name, price_cents, currency, duration_days, data_mb, updated_at FROM p
+	)
+	SELECT * FROM p`
+
+// CreatePlan inserts plan as version 1 of a new plan.
 func (db *DB) CreatePlan(ctx context.Context, plan Plan) (Plan, error) {
-	query := `INSERT INTO plans (code, name, price_cents, currency, duration_days, data_mb, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-	var id uuid.UUID
-	err := db.Pool.QueryRow(ctx, query,
+	query := `WITH p AS (
+				INSERT INTO plans (code, name, price_cents, currency, duration_days, data_mb, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *
+			  ),` + planVersionInsert
+	row := db.conn(ctx).QueryRow(ctx, query,
 		plan.Code,
 		plan.Name,
 		plan.PriceCents,
@@ -20,12 +35,12 @@ func (db *DB) CreatePlan(ctx context.Context, plan Plan) (Plan, error) {
 		plan.DataMB,
 		plan.CreatedAt,
 		plan.UpdatedAt,
-	).Scan(&id)
+	)
+	createdPlan, err := db.scanPlan(ctx, row)
 	if err != nil {
-		return Plan{}, err
+		return Plan{}, mapError(err, nil)
 	}
-	plan.ID = id
-	return plan, nil
+	return createdPlan, nil
 }
 
 func (db *DB) scanPlan(ctx context.Context, row pgx.Row) (Plan, error) {
@@ -41,48 +56,160 @@ func (db *DB) scanPlan(ctx context.Context, row pgx.Row) (Plan, error) {
 		&plan.Active,
 		&plan.CreatedAt,
 		&plan.UpdatedAt,
+		&plan.Version,
+		&plan.RetiredAt,
+		&plan.SuccessorPlanID,
 	)
 	return plan, err
 }
 
-func (db *DB) GetPlans(ctx context.Context, pageableRequest PageableRequest) (Page[Plan], error) {
-	offset := (pageableRequest.Page - 1) * pageableRequest.PageSize
-	pageSize := pageableRequest.PageSize
-	query := `SELECT * from plans ORDER BY created_at DESC LIMIT $1 OFFSET $2`
-	rows, err := db.Pool.Query(ctx, query, pageSize, offset)
-	if err != nil {
-		return Page[Plan]{}, err
+// planSortColumns whitelists the fields GetPlans can sort by. Text sorts by
+// byte value rather than by the database's collation, so that the order does
+// not depend on how the database was set up.
+var planSortColumns = map[string]string{
+	"code":          `code COLLATE "C"`,
+	"name":          `name COLLATE "C"`,
+	"price_cents":   "price_cents",
+	"currency":      `currency COLLATE "C"`,
+	"duration_days": "duration_days",
+	"data_mb":       "data_mb",
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+}
+
+// likeEscaper escapes the LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// planConditions builds the WHERE conditions for filter, appending their
+// arguments.
+func planConditions(filter PlanFilter, args []any) ([]string, []any) {
+	var conditions []string
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Active != nil {
+		add("active = $%d", *filter.Active)
+	}
+	if filter.Currency != "" {
+		add("currency = $%d", filter.Currency)
+	}
+	if filter.MinPriceCents != nil {
+		add("price_cents >= $%d", *filter.MinPriceCents)
+	}
+	if filter.MaxPriceCents != nil {
+		add("price_cents <= $%d", *filter.MaxPriceCents)
+	}
+	if filter.MinDurationDays != nil {
+		add("duration_days >= $%d", *filter.MinDurationDays)
+	}
+	if filter.MaxDurationDays != nil {
+		add("duration_days <= $%d", *filter.MaxDurationDays)
+	}
+	if filter.CodePrefix != "" {
+		add("code LIKE $%d", likeEscaper.Replace(filter.CodePrefix)+"%")
 	}
-	defer rows.Close()
-	var plans []Plan
-	for rows.Next() {
-		plan, err := db.scanPlan(ctx, rows)
-		if err != nil {
-			return Page[Plan]{}, err
+	if filter.Name != "" {
+		add("name ILIKE $%d", "%"+likeEscaper.Replace(filter.Name)+"%")
+	}
+	return conditions, args
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// planOrderBy translates sort into an ORDER BY clause. Only whitelisted
+// column names are ever interpolated; id breaks ties so pages are stable.
+func planOrderBy(sort []SortField) (string, error) {
+	if len(sort) == 0 {
+		return keysetOrder, nil
+	}
+	terms := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		column, ok := planSortColumns[field.Field]
+		if !ok {
+			return "", apperrors.Validation("unsupported sort field %q", field.Field)
 		}
-		plans = append(plans, plan)
+		if field.Descending {
+			column += " DESC"
+		}
+		terms = append(terms, column)
+	}
+	return " ORDER BY " + strings.Join(append(terms, "id"), ", "), nil
+}
+
+// GetPlans returns the plans matching filter. Cursor pagination only
+// follows the default newest first order and skips the total count.
+func (db *DB) GetPlans(ctx context.Context, filter PlanFilter, pageableRequest PageableRequest) (Page[Plan], error) {
+	if pageableRequest.Cursor != "" && len(filter.Sort) > 0 {
+		return Page[Plan]{}, apperrors.Validation("cursor cannot be combined with sort")
+	}
+	orderBy, err := planOrderBy(filter.Sort)
+	if err != nil {
+		return Page[Plan]{}, err
 	}
-	var totalCount int64
-	countQuery := `SELECT COUNT(*) FROM plans`
-	err = db.Pool.QueryRow(ctx, countQuery).Scan(&totalCount)
+	conditions, args := planConditions(filter, nil)
+	keyset, pageArgs, err := keysetCondition(pageableRequest, args)
 	if err != nil {
 		return Page[Plan]{}, err
 	}
-	return Page[Plan]{
-		TotalCount: totalCount,
-		Items:      plans,
-	}, nil
+	pageConditions := conditions
+	if keyset != "" {
+		pageConditions = append(slices.Clip(conditions), keyset)
+	}
+	limit, pageArgs := limitOffset(pageableRequest, pageArgs)
+	query := `SELECT * from plans` + where(pageConditions) + orderBy + limit
+	rows, err := db.conn(ctx).Query(ctx, query, pageArgs...)
+	if err != nil {
+		return Page[Plan]{}, mapError(err, nil)
+	}
+	plans, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Plan, error) {
+		return db.scanPlan(ctx, row)
+	})
+	if err != nil {
+		return Page[Plan]{}, mapError(err, nil)
+	}
+	page := newPage(plans, pageableRequest, len(filter.Sort) == 0)
+	if pageableRequest.Cursor == "" {
+		var totalCount int64
+		countQuery := `SELECT COUNT(*) FROM plans` + where(conditions)
+		if err := db.conn(ctx).QueryRow(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+			return Page[Plan]{}, mapError(err, nil)
+		}
+		page.TotalCount = &totalCount
+	}
+	return page, nil
 }
 
 func (db *DB) GetPlan(ctx context.Context, id string) (Plan, error) {
 	query := `SELECT * from plans WHERE id = $1`
-	row := db.Pool.QueryRow(ctx, query, id)
-	return db.scanPlan(ctx, row)
+	row := db.conn(ctx).QueryRow(ctx, query, id)
+	plan, err := db.scanPlan(ctx, row)
+	return plan, mapError(err, apperrors.ErrPlanNotFound)
 }
 
+// GetPlanByCode looks a plan up by its unique, case-sensitive code.
+func (db *DB) GetPlanByCode(ctx context.Context, code string) (Plan, error) {
+	query := `SELECT * from plans WHERE code = $1`
+	row := db.conn(ctx).QueryRow(ctx, query, code)
+	plan, err := db.scanPlan(ctx, row)
+	return plan, mapError(err, apperrors.ErrPlanNotFound)
+}
+
+// UpdatePlan overwrites the plan's current terms and records them as its
+// next version. Earlier versions are left untouched. A non-zero plan.Version
+// must equal the stored version, otherwise apperrors.ErrPlanModified is
+// returned and nothing changes.
 func (db *DB) UpdatePlan(ctx context.Context, plan Plan) (Plan, error) {
-	query := `UPDATE plans SET code = $1, name = $2, price_cents = $3, currency = $4, duration_days = $5, data_mb = $6, active = $7, updated_at = $8 WHERE id = $9`
-	_, err := db.Pool.Exec(ctx, query,
+	query := `WITH p AS (
+				UPDATE plans SET code = $1, name = $2, price_cents = $3, currency = $4, duration_days = $5, data_mb = $6, active = $7, updated_at = $8, version = version + 1
+				WHERE id = $9 AND ($10 = 0 OR version = $10) RETURNING *
+			  ),` + planVersionInsert
+	row := db.conn(ctx).QueryRow(ctx, query,
 		plan.Code,
 		plan.Name,
 		plan.PriceCents,
@@ -92,9 +219,71 @@ func (db *DB) UpdatePlan(ctx context.Context, plan Plan) (Plan, error) {
 		plan.Active,
 		plan.UpdatedAt,
 		plan.ID,
+		plan.Version,
+	)
+	updatedPlan, err := db.scanPlan(ctx, row)
+	if errors.Is(err, pgx.ErrNoRows) && plan.Version != 0 {
+		if _, err := db.GetPlan(ctx, plan.ID.String()); err != nil {
+			return Plan{}, err
+		}
+		return Plan{}, apperrors.ErrPlanModified
+	}
+	if err != nil {
+		return Plan{}, mapError(err, apperrors.ErrPlanNotFound)
+	}
+	return updatedPlan, nil
+}
+
+// RetirePlan schedules the plan's retirement at retiredAt, naming successor
+// as the plan its subscribers renew onto. The plan's terms and version are
+// unchanged.
+func (db *DB) RetirePlan(ctx context.Context, plan Plan) (Plan, error) {
+	query := `UPDATE plans SET retired_at = $1, successor_plan_id = $2, updated_at = $3 WHERE id = $4 RETURNING *`
+	row := db.conn(ctx).QueryRow(ctx, query, plan.RetiredAt, plan.SuccessorPlanID, plan.UpdatedAt, plan.ID)
+	retiredPlan, err := db.scanPlan(ctx, row)
+	if err != nil {
+		return Plan{}, mapError(err, apperrors.ErrPlanNotFound)
+	}
+	return retiredPlan, nil
+}
+
+func scanPlanVersion(row pgx.Row) (PlanVersion, error) {
+	var version PlanVersion
+	err := row.Scan(
+		&version.PlanID,
+		&version.Version,
+		&version.Code,
+		&version.Name,
+		&version.PriceCents,
+		&version.Currency,
+		&version.DurationDays,
+		&version.DataMB,
+		&version.CreatedAt,
 	)
+	return version, err
+}
+
+// GetPlanVersions returns every version of a plan, newest first.
+func (db *DB) GetPlanVersions(ctx context.Context, planId string) ([]PlanVersion, error) {
+	query := `SELECT * FROM plan_versions WHERE plan_id = $1 ORDER BY version DESC`
+	rows, err := db.conn(ctx).Query(ctx, query, planId)
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	versions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (PlanVersion, error) {
+		return scanPlanVersion(row)
+	})
 	if err != nil {
-		return Plan{}, err
+		return nil, mapError(err, nil)
 	}
-	return plan, nil
+	if len(versions) == 0 {
+		return nil, apperrors.ErrPlanNotFound
+	}
+	return versions, nil
+}
+
+func (db *DB) GetPlanVersion(ctx context.Context, planId string, version int) (PlanVersion, error) {
+	query := `SELECT * FROM plan_versions WHERE plan_id = $1 AND version = $2`
+	planVersion, err := scanPlanVersion(db.conn(ctx).QueryRow(ctx, query, planId, version))
+	return planVersion, mapError(err, apperrors.ErrPlanNotFound)
 }
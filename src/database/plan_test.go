@@ -35,7 +35,7 @@ func TestGetPlans(t *testing.T) {
 		Page:     1,
 		PageSize: 10,
 	}
-	if page, err := db.GetPlans(ctx, pageableRequest); err != nil {
+	if page, err := db.GetPlans(ctx, PlanFilter{}, pageableRequest); err != nil {
 		t.Fatalf("Failed to get plans: %v", err)
 	} else {
 		t.Logf("Successfully retrieved %d plans", len(page.Items))
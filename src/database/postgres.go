@@ -1,12 +1,17 @@
 package database
 
 import (
+	"bss/src/apperrors"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
 )
 
 // Config holds the database configuration
@@ -39,6 +44,18 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// SQLSTATE codes and constraint names that mapError translates.
+const (
+	uniqueViolation           = "23505"
+	foreignKeyViolation       = "23503"
+	invalidTextRepresentation = "22P02"
+
+	planCodeConstraint                = "plans_code_key"
+	subscriptionPlanConstraint        = "subscriptions_plan_id_fkey"
+	subscriptionPlanVersionConstraint = "subscriptions_plan_version_fkey"
+	oneActivePerCustomerIndex         = "idx_subscriptions_one_active_per_customer"
+)
+
 // DB wraps the pgxpool connection
 type DB struct {
 	Pool *pgxpool.Pool
@@ -72,6 +89,7 @@ func NewDB(ctx context.Context, config *Config) (*DB, error) {
 	poolConfig.MaxConnLifetime = time.Hour
 	poolConfig.MaxConnIdleTime = 30 * time.Minute
 	poolConfig.HealthCheckPeriod = time.Minute
+	poolConfig.ConnConfig.Tracer = newQueryTracer(otel.GetTracerProvider())
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -98,3 +116,31 @@ func (db *DB) Close() {
 func (db *DB) Ping(ctx context.Context) error {
 	return db.Pool.Ping(ctx)
 }
+
+// mapError translates driver errors into apperrors so that callers never see
+// raw pgx messages. notFound, when not nil, is returned for pgx.ErrNoRows.
+func mapError(err error, notFound error) error {
+	var pgErr *pgconn.PgError
+	var connectErr *pgconn.ConnectError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, pgx.ErrNoRows) && notFound != nil:
+		return notFound
+	case errors.As(err, &pgErr):
+		switch {
+		case pgErr.Code == uniqueViolation && pgErr.ConstraintName == planCodeConstraint:
+			return apperrors.ErrPlanAlreadyExists
+		case pgErr.Code == uniqueViolation && pgErr.ConstraintName == oneActivePerCustomerIndex:
+			return apperrors.ErrSubscriptionAlreadyExists
+		case pgErr.Code == foreignKeyViolation && (pgErr.ConstraintName == subscriptionPlanConstraint || pgErr.ConstraintName == subscriptionPlanVersionConstraint):
+			return apperrors.ErrPlanNotFound
+		case pgErr.Code == invalidTextRepresentation && notFound != nil:
+			// A malformed id, such as a non-UUID plan id, matches no row.
+			return notFound
+		}
+	case errors.As(err, &connectErr) || pgconn.Timeout(err):
+		return apperrors.Wrap(apperrors.CodeServiceUnavailable, "database unavailable", err)
+	}
+	return err
+}
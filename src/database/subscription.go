@@ -1,10 +1,10 @@
 package database
 
 import (
+	"bss/src/apperrors"
 	"context"
-	"errors"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -18,62 +18,92 @@ func scanSubscription(row pgx.Row) (Subscription, error) {
 		&subscription.Status,
 		&subscription.AutoRenew,
 		&subscription.CreatedAt,
-		&subscription.UpdatedAt)
+		&subscription.UpdatedAt,
+		&subscription.RenewedFrom,
+		&subscription.PlanVersion)
 	return subscription, err
 }
 
 func (db *DB) GetSubscriptionsByUserId(ctx context.Context, pageableRequest PageableRequest, userId string) (Page[Subscription], error) {
-	offset := (pageableRequest.Page - 1) * pageableRequest.PageSize
-	pageSize := pageableRequest.PageSize
-	query := `SELECT * 
-			  FROM subscriptions 
-			  WHERE customer_id = $1
-			  ORDER BY created_at DESC
-			  LIMIT $2 OFFSET $3`
-	rows, err := db.Pool.Query(ctx, query, userId, pageSize, offset)
+	conditions := []string{"customer_id = $1"}
+	keyset, args, err := keysetCondition(pageableRequest, []any{userId})
 	if err != nil {
 		return Page[Subscription]{}, err
 	}
-	defer rows.Close()
-
-	var subscriptions []Subscription
-	for rows.Next() {
-		subscription, err := scanSubscription(rows)
-		if err != nil {
-			return Page[Subscription]{}, err
-		}
-		subscriptions = append(subscriptions, subscription)
+	if keyset != "" {
+		conditions = append(conditions, keyset)
 	}
-	var totalCount int64
-	countQuery := `SELECT COUNT(*) 
-				   FROM subscriptions 
-				   WHERE customer_id = $1`
-	err = db.Pool.QueryRow(ctx, countQuery, userId).Scan(&totalCount)
+	limit, args := limitOffset(pageableRequest, args)
+	query := `SELECT * FROM subscriptions` + where(conditions) + keysetOrder + limit
+	rows, err := db.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
-		return Page[Subscription]{}, err
+		return Page[Subscription]{}, mapError(err, nil)
 	}
-	return Page[Subscription]{
-		TotalCount: totalCount,
-		Items:      subscriptions,
-	}, nil
+	subscriptions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Subscription, error) {
+		return scanSubscription(row)
+	})
+	if err != nil {
+		return Page[Subscription]{}, mapError(err, nil)
+	}
+	page := newPage(subscriptions, pageableRequest, true)
+	if pageableRequest.Cursor == "" {
+		var totalCount int64
+		countQuery := `SELECT COUNT(*) 
+					   FROM subscriptions 
+					   WHERE customer_id = $1`
+		if err := db.conn(ctx).QueryRow(ctx, countQuery, userId).Scan(&totalCount); err != nil {
+			return Page[Subscription]{}, mapError(err, nil)
+		}
+		page.TotalCount = &totalCount
+	}
+	return page, nil
 }
 
 func (db *DB) GetActiveSubscriptionByUserId(ctx context.Context, userId string) (Subscription, error) {
 	query := `SELECT * 
 			  FROM subscriptions 
 			  WHERE customer_id = $1 AND status = 'ACTIVE'`
-	row := db.Pool.QueryRow(ctx, query, userId)
-	return scanSubscription(row)
+	row := db.conn(ctx).QueryRow(ctx, query, userId)
+	subscription, err := scanSubscription(row)
+	return subscription, mapError(err, apperrors.ErrSubscriptionNotFound)
 }
 
+// CountActiveSubscriptionsByPlan counts the active subscriptions on every
+// plan, including plans that have none.
+func (db *DB) CountActiveSubscriptionsByPlan(ctx context.Context) ([]PlanSubscriptionCount, error) {
+	query := `
+		SELECT p.id, p.code, COUNT(s.id)
+		FROM plans p
+		LEFT JOIN subscriptions s ON s.plan_id = p.id AND s.status = 'ACTIVE'
+		GROUP BY p.id, p.code
+		ORDER BY p.code COLLATE "C"
+	`
+	rows, err := db.conn(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	counts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (PlanSubscriptionCount, error) {
+		var count PlanSubscriptionCount
+		err := row.Scan(&count.PlanID, &count.PlanCode, &count.Active)
+		return count, err
+	})
+	return counts, mapError(err, nil)
+}
+
+// CreateSubscription inserts subscription. A zero PlanVersion means the
+// plan's current version. When RenewedFrom is set and that period has
+// already been renewed, nothing is inserted and
+// apperrors.ErrSubscriptionAlreadyRenewed is returned without aborting the
+// surrounding transaction.
 func (db *DB) CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
 	query := `
-		INSERT INTO subscriptions (customer_id, plan_id, start_date, end_date, status, auto_renew, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id
+		INSERT INTO subscriptions (customer_id, plan_id, start_date, end_date, status, auto_renew, created_at, updated_at, renewed_from, plan_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9,
+				COALESCE(NULLIF($10::int, 0), (SELECT version FROM plans WHERE id = $2), 0))
+		ON CONFLICT (renewed_from) DO NOTHING
+		RETURNING *
 	`
-	var id uuid.UUID
-	err := db.Pool.QueryRow(ctx, query,
+	row := db.conn(ctx).QueryRow(ctx, query,
 		subscription.CustomerID,
 		subscription.PlanID,
 		subscription.StartDate,
@@ -82,26 +112,105 @@ func (db *DB) CreateSubscription(ctx context.Context, subscription Subscription)
 		subscription.AutoRenew,
 		subscription.CreatedAt,
 		subscription.UpdatedAt,
-	).Scan(&id)
+		subscription.RenewedFrom,
+		subscription.PlanVersion,
+	)
+	createdSubscription, err := scanSubscription(row)
 	if err != nil {
-		return Subscription{}, err
+		return Subscription{}, mapError(err, apperrors.ErrSubscriptionAlreadyRenewed)
 	}
-	subscription.ID = id
-	return subscription, nil
+	return createdSubscription, nil
 }
 
-func (db *DB) CancelSubscription(ctx context.Context, subscriptionId string, customerId string) error {
+func (db *DB) CancelSubscription(ctx context.Context, subscriptionId string, customerId string) (Subscription, error) {
 	query := `
 		UPDATE subscriptions
 		SET status = 'CANCELLED', updated_at = NOW()
 		WHERE id = $1 and status = 'ACTIVE' and customer_id = $2
+		RETURNING *
+	`
+	subscription, err := scanSubscription(db.conn(ctx).QueryRow(ctx, query, subscriptionId, customerId))
+	if err != nil {
+		return Subscription{}, mapError(err, apperrors.ErrSubscriptionNotFound)
+	}
+	return subscription, nil
+}
+
+// renewablePlan matches the plans p a due subscription s renews on: active
+// ones whose retirement has not taken effect by the renewal, or that name a
+// successor to migrate to.
+const renewablePlan = `p.active AND (p.retired_at IS NULL OR s.end_date < p.retired_at OR p.successor_plan_id IS NOT NULL)`
+
+// ExpireSubscriptions marks up to limit ACTIVE subscriptions whose end date
+// is not after now as EXPIRED. Subscriptions that are due for auto-renewal,
+// i.e. auto-renew ones on a renewable plan, are left alone. Due rows are
+// claimed with SKIP LOCKED, so concurrent sweeps from several replicas split
+// the work instead of blocking or double-expiring.
+func (db *DB) ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	query := `
+		WITH due AS (
+			SELECT s.id
+			FROM subscriptions s
+			WHERE s.status = 'ACTIVE' AND s.end_date <= $1
+			  AND NOT (s.auto_renew AND EXISTS (
+				  SELECT 1 FROM plans p WHERE p.id = s.plan_id AND ` + renewablePlan + `
+			  ))
+			ORDER BY s.end_date
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE subscriptions s
+		SET status = 'EXPIRED', updated_at = NOW()
+		FROM due
+		WHERE s.id = due.id
+		RETURNING s.*
 	`
-	result, err := db.Pool.Exec(ctx, query, subscriptionId, customerId)
+	rows, err := db.conn(ctx).Query(ctx, query, now, limit)
 	if err != nil {
-		return err
+		return nil, mapError(err, nil)
 	}
-	if result.RowsAffected() == 0 {
-		return errors.New("no matching row found")
+	expired, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Subscription, error) {
+		return scanSubscription(row)
+	})
+	if err != nil {
+		return nil, mapError(err, nil)
 	}
-	return nil
+	return expired, nil
+}
+
+// LockDueRenewals locks up to limit ACTIVE auto-renew subscriptions on
+// renewable plans whose end date is not after now. It must be called inside
+// WithinTx; the locks are held until that transaction ends and rows locked by
+// another sweep are skipped.
+func (db *DB) LockDueRenewals(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	query := `
+		SELECT s.*
+		FROM subscriptions s
+		JOIN plans p ON p.id = s.plan_id
+		WHERE s.status = 'ACTIVE' AND s.auto_renew AND ` + renewablePlan + ` AND s.end_date <= $1
+		ORDER BY s.end_date
+		LIMIT $2
+		FOR UPDATE OF s SKIP LOCKED
+	`
+	rows, err := db.conn(ctx).Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	due, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Subscription, error) {
+		return scanSubscription(row)
+	})
+	if err != nil {
+		return nil, mapError(err, nil)
+	}
+	return due, nil
+}
+
+// UpdateSubscriptionStatus sets the status of a single subscription.
+func (db *DB) UpdateSubscriptionStatus(ctx context.Context, id string, status SubscriptionStatus) (Subscription, error) {
+	query := `UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING *`
+	subscription, err := scanSubscription(db.conn(ctx).QueryRow(ctx, query, status, id))
+	if err != nil {
+		return Subscription{}, mapError(err, apperrors.ErrSubscriptionNotFound)
+	}
+	return subscription, nil
 }
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"bss/src/apperrors"
+	"errors"
 	"testing"
 	"time"
 
@@ -89,7 +91,7 @@ func TestCancelSubscriptionBadSubId(t *testing.T) {
 	defer db.Close()
 	subscriptionId := "00000000-0000-0000-0000-000000000010"
 	customerId := "00000000-0000-0000-0000-000000000000"
-	err := db.CancelSubscription(ctx, subscriptionId, customerId)
+	_, err := db.CancelSubscription(ctx, subscriptionId, customerId)
 	if err == nil {
 		t.Fatalf("Expected failure when canceling subscription with bad ID, but got success")
 	}
@@ -100,8 +102,60 @@ func TestCancelSubscription(t *testing.T) {
 	defer db.Close()
 	subscriptionId := "22222222-2222-2222-2222-222222222222"
 	customerId := "00000000-0000-0000-0000-000000000000"
-	err := db.CancelSubscription(ctx, subscriptionId, customerId)
+	_, err := db.CancelSubscription(ctx, subscriptionId, customerId)
 	if err != nil {
 		t.Fatalf("Failed to cancel subscription: %v", err)
 	}
 }
+
+func TestCreateSubscriptionRenewedTwice(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+	previous, err := db.CreateSubscription(ctx, Subscription{
+		CustomerID: uuid.New(),
+		PlanID:     uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		StartDate:  time.Now().Add(-30 * 24 * time.Hour),
+		EndDate:    time.Now(),
+		Status:     "EXPIRED",
+		AutoRenew:  true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	renewal := Subscription{
+		CustomerID:  previous.CustomerID,
+		PlanID:      previous.PlanID,
+		StartDate:   previous.EndDate,
+		EndDate:     previous.EndDate.Add(30 * 24 * time.Hour),
+		Status:      "EXPIRED",
+		AutoRenew:   true,
+		RenewedFrom: &previous.ID,
+	}
+	if _, err := db.CreateSubscription(ctx, renewal); err != nil {
+		t.Fatalf("Failed to create renewal: %v", err)
+	}
+	_, err = db.CreateSubscription(ctx, renewal)
+	if !errors.Is(err, apperrors.ErrSubscriptionAlreadyRenewed) {
+		t.Fatalf("Expected ErrSubscriptionAlreadyRenewed, got %v", err)
+	}
+}
+
+func TestCreateSubscriptionTwice(t *testing.T) {
+	ctx, db := createDbForPlanTests(t)
+	defer db.Close()
+	subscription := Subscription{
+		CustomerID: uuid.New(),
+		PlanID:     uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		StartDate:  time.Now(),
+		EndDate:    time.Now().Add(30 * 24 * time.Hour),
+		Status:     "ACTIVE",
+		AutoRenew:  true,
+	}
+	if _, err := db.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	_, err := db.CreateSubscription(ctx, subscription)
+	if !errors.Is(err, apperrors.ErrSubscriptionAlreadyExists) {
+		t.Fatalf("Expected ErrSubscriptionAlreadyExists, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"bss/src/tracing"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestQueryTracer(t *testing.T) {
+	testCases := []struct {
+		name       string
+		sql        string
+		err        error
+		wantName   string
+		wantStatus codes.Code
+	}{
+		{"Select", "\n\t\tSELECT * FROM plans WHERE id = $1", nil, "SELECT", codes.Unset},
+		{"Failed", "insert into plans (code) values ($1)", errors.New("duplicate key"), "INSERT", codes.Error},
+		{"Empty", "", nil, "QUERY", codes.Unset},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, exporter := tracing.NewInMemoryProvider()
+			parentCtx, parent := provider.Tracer("test").Start(context.Background(), "request")
+			tracer := newQueryTracer(provider)
+
+			ctx := tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: tc.sql, Args: []any{"secret"}})
+			tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1"), Err: tc.err})
+			parent.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("Expected a query span and its parent, got %d spans", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tc.wantName || span.SpanKind != trace.SpanKindClient {
+				t.Errorf("Expected client span %q, got %s span %q", tc.wantName, span.SpanKind, span.Name)
+			}
+			if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("Expected the query span to be a child of the request span")
+			}
+			if span.Status.Code != tc.wantStatus {
+				t.Errorf("Expected status %v, got %v", tc.wantStatus, span.Status)
+			}
+			for _, kv := range span.Attributes {
+				if kv.Value.Emit() == "secret" {
+					t.Errorf("Expected query arguments not to be recorded, got %v", kv)
+				}
+			}
+		})
+	}
+}
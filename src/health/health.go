@@ -0,0 +1,154 @@
+// Package health runs the named dependency checks behind /healthz and
+// /readyz. Components register a check with a timeout, and every probe runs
+// all of them concurrently and reports each one's status and latency.
+package health
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK           Status = "ok"
+	StatusDegraded     Status = "degraded"
+	StatusShuttingDown Status = "shutting_down"
+	StatusFailed       Status = "failed"
+)
+
+// DefaultTimeout bounds checks registered without a timeout of their own.
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable. It must return promptly once
+// ctx is done.
+type Check func(ctx context.Context) error
+
+type registeredCheck struct {
+	name    string
+	timeout time.Duration
+	check   Check
+}
+
+// The reports are served unauthenticated, so a failed check only says
+// whether it failed or timed out; the error itself, which may name hosts and
+// users, is logged.
+const (
+	errorFailed  = "failed"
+	errorTimeout = "timeout"
+)
+
+// CheckResult is the outcome of one check in a Report.
+type CheckResult struct {
+	Status    Status  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of running every registered check. Status is
+// StatusDegraded when any check failed and StatusShuttingDown once Shutdown
+// has been called, whatever the checks say.
+type Report struct {
+	Status Status                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Ready reports whether the instance should receive traffic.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type Registry struct {
+	mu           sync.RWMutex
+	checks       []registeredCheck
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a check under name. A timeout of zero means DefaultTimeout.
+// Registering a name twice replaces the earlier check.
+func (r *Registry) Register(name string, timeout time.Duration, check Check) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks[i] = registeredCheck{name: name, timeout: timeout, check: check}
+			return
+		}
+	}
+	r.checks = append(r.checks, registeredCheck{name: name, timeout: timeout, check: check})
+}
+
+// Shutdown marks the instance as shutting down, so that readiness fails
+// while in-flight requests drain. It cannot be undone.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Run runs every check concurrently, each under its own timeout, and waits
+// for all of them.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]registeredCheck(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	if r.ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c registeredCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := make(chan error, 1)
+	// A check that ignores ctx must not hold up the whole report.
+	go func() { err <- c.check(ctx) }()
+	var result CheckResult
+	select {
+	case e := <-err:
+		if e != nil {
+			log.Printf("health: %s check failed: %v", c.name, e)
+			result = CheckResult{Status: StatusFailed, Error: errorFailed}
+		} else {
+			result = CheckResult{Status: StatusOK}
+		}
+	case <-ctx.Done():
+		log.Printf("health: %s check timed out after %s", c.name, c.timeout)
+		result = CheckResult{Status: StatusFailed, Error: errorTimeout}
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryRun(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	hanging := func(ctx context.Context) error { select {} }
+
+	testCases := []struct {
+		name       string
+		checks     map[string]Check
+		shutdown   bool
+		wantStatus Status
+		wantChecks map[string]Status
+	}{
+		{
+			name:       "NoChecks",
+			wantStatus: StatusOK,
+			wantChecks: map[string]Status{},
+		},
+		{
+			name:       "AllPassing",
+			checks:     map[string]Check{"database": ok, "publisher": ok},
+			wantStatus: StatusOK,
+			wantChecks: map[string]Status{"database": StatusOK, "publisher": StatusOK},
+		},
+		{
+			name:       "OneFailing",
+			checks:     map[string]Check{"database": ok, "publisher": failing},
+			wantStatus: StatusDegraded,
+			wantChecks: map[string]Status{"database": StatusOK, "publisher": StatusFailed},
+		},
+		{
+			name:       "TimedOut",
+			checks:     map[string]Check{"database": hanging},
+			wantStatus: StatusDegraded,
+			wantChecks: map[string]Status{"database": StatusFailed},
+		},
+		{
+			name:       "ShuttingDown",
+			checks:     map[string]Check{"database": ok},
+			shutdown:   true,
+			wantStatus: StatusShuttingDown,
+			wantChecks: map[string]Status{"database": StatusOK},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := NewRegistry()
+			for name, check := range tc.checks {
+				registry.Register(name, 10*time.Millisecond, check)
+			}
+			if tc.shutdown {
+				registry.Shutdown()
+			}
+			report := registry.Run(context.Background())
+			if report.Status != tc.wantStatus {
+				t.Errorf("Expected status %s, got %s", tc.wantStatus, report.Status)
+			}
+			if report.Ready() != (tc.wantStatus == StatusOK) {
+				t.Errorf("Unexpected readiness %v for status %s", report.Ready(), report.Status)
+			}
+			if len(report.Checks) != len(tc.wantChecks) {
+				t.Fatalf("Expected checks %v, got %v", tc.wantChecks, report.Checks)
+			}
+			for name, want := range tc.wantChecks {
+				got := report.Checks[name]
+				if got.Status != want {
+					t.Errorf("Expected %s to be %s, got %+v", name, want, got)
+				}
+				if (got.Error != "") != (want == StatusFailed) {
+					t.Errorf("Unexpected error %q for %s", got.Error, name)
+				}
+				if got.Error != "" && got.Error != errorFailed && got.Error != errorTimeout {
+					t.Errorf("Expected the error of %s not to leak its cause, got %q", name, got.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterReplacesCheck(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("database", 0, func(ctx context.Context) error { return errors.New("down") })
+	registry.Register("database", 0, func(ctx context.Context) error { return nil })
+	report := registry.Run(context.Background())
+	if len(report.Checks) != 1 || report.Checks["database"].Status != StatusOK {
+		t.Errorf("Expected the second registration to win, got %+v", report.Checks)
+	}
+}
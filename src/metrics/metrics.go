@@ -0,0 +1,154 @@
+// Package metrics exposes the server's Prometheus metrics: HTTP request
+// counts and latencies, connection pool stats and the business metrics from
+// HLD §6.
+package metrics
+
+import (
+	"bss/src/models"
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests no route matched, so that scanners probing
+// random paths cannot blow up the label cardinality.
+const unmatchedRoute = "unmatched"
+
+// Metrics owns a Prometheus registry and the collectors the server records
+// to. Each Metrics is independent, so tests can create their own.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests               *prometheus.CounterVec
+	requestDuration        *prometheus.HistogramVec
+	subscriptionsCreated   *prometheus.CounterVec
+	subscriptionsCancelled *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency, by route pattern, method and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		subscriptionsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "subscriptions_created_total",
+			Help: "Subscriptions started by customers, by plan.",
+		}, []string{"plan_id"}),
+		subscriptionsCancelled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "subscriptions_cancelled_total",
+			Help: "Subscriptions cancelled by customers, by plan.",
+		}, []string{"plan_id"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.subscriptionsCreated,
+		m.subscriptionsCancelled,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format. A
+// collector that fails, such as the active subscriptions count while the
+// database is down, is left out instead of failing the whole scrape.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{
+		Registry:      m.Registry,
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Middleware records every request under the chi route pattern it matched,
+// such as /plans/{id}, rather than the raw path. It must be installed on the
+// top-level chi router.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (m *Metrics) SubscriptionCreated(subscription models.Subscription) {
+	m.subscriptionsCreated.WithLabelValues(subscription.PlanID.String()).Inc()
+}
+
+func (m *Metrics) SubscriptionCancelled(subscription models.Subscription) {
+	m.subscriptionsCancelled.WithLabelValues(subscription.PlanID.String()).Inc()
+}
+
+// RegisterPool exports the stats of a pgx connection pool.
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool) {
+	m.Registry.MustRegister(newPoolCollector(pool.Stat))
+}
+
+// ActiveSubscriptionStore counts active subscriptions per plan.
+type ActiveSubscriptionStore interface {
+	CountActiveSubscriptionsByPlan(ctx context.Context) ([]models.PlanSubscriptionCount, error)
+}
+
+// RegisterActiveSubscriptions exports the number of active subscriptions per
+// plan, counted by store on every scrape and given up on after timeout.
+func (m *Metrics) RegisterActiveSubscriptions(store ActiveSubscriptionStore, timeout time.Duration) {
+	m.Registry.MustRegister(&activeSubscriptionsCollector{store: store, timeout: timeout})
+}
+
+var activeSubscriptionsDesc = prometheus.NewDesc(
+	"subscriptions_active",
+	"Active subscriptions, by plan.",
+	[]string{"plan_id", "plan_code"}, nil,
+)
+
+type activeSubscriptionsCollector struct {
+	store   ActiveSubscriptionStore
+	timeout time.Duration
+}
+
+func (c *activeSubscriptionsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSubscriptionsDesc
+}
+
+func (c *activeSubscriptionsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.store.CountActiveSubscriptionsByPlan(ctx)
+	if err != nil {
+		log.Printf("metrics: failed to count active subscriptions: %v", err)
+		ch <- prometheus.NewInvalidMetric(activeSubscriptionsDesc, err)
+		return
+	}
+	for _, count := range counts {
+		ch <- prometheus.MustNewConstMetric(activeSubscriptionsDesc, prometheus.GaugeValue,
+			float64(count.Active), count.PlanID.String(), count.PlanCode)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bss/src/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	m := New()
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/plans/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	router.Post("/plans", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/plans/1"},
+		{http.MethodGet, "/plans/2"},
+		{http.MethodPost, "/plans"},
+		{http.MethodGet, "/wp-admin"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	testCases := []struct {
+		route, method, status string
+		want                  float64
+	}{
+		{"/plans/{id}", http.MethodGet, "200", 2},
+		{"/plans", http.MethodPost, "201", 1},
+		{unmatchedRoute, http.MethodGet, "404", 1},
+	}
+	for _, tc := range testCases {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues(tc.route, tc.method, tc.status)); got != tc.want {
+			t.Errorf("Expected %v requests for %s %s %s, got %v", tc.want, tc.method, tc.route, tc.status, got)
+		}
+	}
+	if n := testutil.CollectAndCount(m.requestDuration); n != len(testCases) {
+		t.Errorf("Expected %d latency series, got %d", len(testCases), n)
+	}
+}
+
+func TestSubscriptionCounters(t *testing.T) {
+	m := New()
+	planId := uuid.New()
+	m.SubscriptionCreated(models.Subscription{PlanID: planId})
+	m.SubscriptionCreated(models.Subscription{PlanID: planId})
+	m.SubscriptionCancelled(models.Subscription{PlanID: planId})
+
+	if got := testutil.ToFloat64(m.subscriptionsCreated.WithLabelValues(planId.String())); got != 2 {
+		t.Errorf("Expected 2 created subscriptions, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.subscriptionsCancelled.WithLabelValues(planId.String())); got != 1 {
+		t.Errorf("Expected 1 cancelled subscription, got %v", got)
+	}
+}
+
+type fakeActiveStore struct {
+	counts []models.PlanSubscriptionCount
+	err    error
+}
+
+func (s fakeActiveStore) CountActiveSubscriptionsByPlan(ctx context.Context) ([]models.PlanSubscriptionCount, error) {
+	return s.counts, s.err
+}
+
+func TestActiveSubscriptions(t *testing.T) {
+	planId := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	m := New()
+	m.RegisterActiveSubscriptions(fakeActiveStore{counts: []models.PlanSubscriptionCount{
+		{PlanID: planId, PlanCode: "BASIC-MONTHLY", Active: 3},
+	}}, time.Second)
+
+	want := `
+# HELP subscriptions_active Active subscriptions, by plan.
+# TYPE subscriptions_active gauge
+subscriptions_active{plan_code="BASIC-MONTHLY",plan_id="11111111-1111-1111-1111-111111111111"} 3
+`
+	if err := testutil.GatherAndCompare(m.Registry, strings.NewReader(want), "subscriptions_active"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestActiveSubscriptionsStoreError(t *testing.T) {
+	m := New()
+	m.RegisterActiveSubscriptions(fakeActiveStore{err: errors.New("connection refused")}, time.Second)
+	if _, err := m.Registry.Gather(); err == nil {
+		t.Error("Expected the failed count to be reported to the scraper")
+	}
+}
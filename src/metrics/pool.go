@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredConns = prometheus.NewDesc("pgxpool_acquired_conns",
+		"Connections currently checked out of the pool.", nil, nil)
+	poolIdleConns = prometheus.NewDesc("pgxpool_idle_conns",
+		"Idle connections in the pool.", nil, nil)
+	poolTotalConns = prometheus.NewDesc("pgxpool_total_conns",
+		"Connections in the pool, including ones being established.", nil, nil)
+	poolMaxConns = prometheus.NewDesc("pgxpool_max_conns",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquires = prometheus.NewDesc("pgxpool_acquires_total",
+		"Successful connection acquisitions.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("pgxpool_empty_acquires_total",
+		"Acquisitions that had to wait for a connection.", nil, nil)
+	poolCanceledAcquires = prometheus.NewDesc("pgxpool_canceled_acquires_total",
+		"Acquisitions cancelled by their context.", nil, nil)
+	poolAcquireSeconds = prometheus.NewDesc("pgxpool_acquire_duration_seconds_total",
+		"Time spent acquiring connections.", nil, nil)
+)
+
+// poolCollector reads pgxpool.Stat on every scrape.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+func newPoolCollector(stat func() *pgxpool.Stat) *poolCollector {
+	return &poolCollector{stat: stat}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+	gauge(poolAcquiredConns, float64(stat.AcquiredConns()))
+	gauge(poolIdleConns, float64(stat.IdleConns()))
+	gauge(poolTotalConns, float64(stat.TotalConns()))
+	gauge(poolMaxConns, float64(stat.MaxConns()))
+	counter(poolAcquires, float64(stat.AcquireCount()))
+	counter(poolEmptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(poolCanceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(poolAcquireSeconds, stat.AcquireDuration().Seconds())
+}
@@ -1,15 +1,125 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	EventTypePlanCreated           = "plan.created"
+	EventTypePlanUpdated           = "plan.updated"
+	EventTypePlanRetired           = "plan.retired"
+	EventTypeSubscriptionCreated   = "subscription.created"
+	EventTypeSubscriptionCancelled = "subscription.cancelled"
+	EventTypeSubscriptionExpired   = "subscription.expired"
+	EventTypeSubscriptionRenewed   = "subscription.renewed"
+)
+
+// EventProducer identifies this service in the envelope of every event it emits.
+const EventProducer = "bss-server"
+
+// Event is a row of the events outbox table. Payload holds the complete JSON
+// message, envelope included, exactly as it is published to the broker.
 type Event struct {
-	ID         int64     `json:"id" db:"id"`
-	EventType  string    `json:"event_type" db:"event_type"`
-	ResourceID uuid.UUID `json:"resource_id" db:"resource_id"`
-	Payload    []byte    `json:"payload" db:"payload"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ID          int64           `json:"id" db:"id"`
+	EventID     uuid.UUID       `json:"event_id" db:"event_id"`
+	EventType   string          `json:"event_type" db:"event_type"`
+	ResourceID  uuid.UUID       `json:"resource_id" db:"resource_id"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	PublishedAt *time.Time      `json:"published_at,omitempty" db:"published_at"`
+	// Attempts counts failed deliveries and LastError holds the latest
+	// failure. An event that failed too often is dead-lettered: the relay
+	// skips it from DeadLetteredAt on.
+	Attempts       int        `json:"attempts,omitempty" db:"attempts"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty" db:"dead_lettered_at"`
+}
+
+// EventFilter selects events for replay. Events are always returned in id
+// order starting after AfterID; a zero Limit means no limit.
+type EventFilter struct {
+	AfterID    int64
+	EventType  string
+	ResourceID *uuid.UUID
+	Limit      int
+}
+
+// EventEnvelope carries the fields common to every published event. The
+// typed payload is flattened next to these fields on the wire, so a
+// subscription.created message looks like
+//
+//	{"event_id": "...", "event_type": "subscription.created", ..., "subscription": {...}}
+type EventEnvelope struct {
+	EventID       uuid.UUID `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	SchemaVersion int       `json:"schema_version"`
+	Producer      string    `json:"producer"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// EventPayload is implemented by every typed event body.
+type EventPayload interface {
+	EventType() string
+	SchemaVersion() int
+	ResourceID() uuid.UUID
+}
+
+// NewEvent wraps payload in a fresh envelope and returns the outbox row for it.
+func NewEvent(payload EventPayload) (Event, error) {
+	envelope := EventEnvelope{
+		EventID:       uuid.New(),
+		EventType:     payload.EventType(),
+		SchemaVersion: payload.SchemaVersion(),
+		Producer:      EventProducer,
+		Timestamp:     time.Now().UTC(),
+	}
+	data, err := MarshalEvent(envelope, payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		EventID:    envelope.EventID,
+		EventType:  envelope.EventType,
+		ResourceID: payload.ResourceID(),
+		Payload:    data,
+		CreatedAt:  envelope.Timestamp,
+	}, nil
+}
+
+// MarshalEvent encodes the envelope and payload as a single flat JSON object.
+func MarshalEvent(envelope EventEnvelope, payload EventPayload) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	for _, part := range []any{payload, envelope} {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalEvent decodes a message produced by MarshalEvent. It fails if the
+// message is of a different event type than T, or of a newer schema version
+// than this build understands.
+func UnmarshalEvent[T EventPayload](data []byte) (EventEnvelope, T, error) {
+	var envelope EventEnvelope
+	var payload T
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, payload, err
+	}
+	if envelope.EventType != payload.EventType() {
+		return envelope, payload, fmt.Errorf("event type %q does not match %q", envelope.EventType, payload.EventType())
+	}
+	if envelope.SchemaVersion > payload.SchemaVersion() {
+		return envelope, payload, fmt.Errorf("unsupported %s schema version %d", envelope.EventType, envelope.SchemaVersion)
+	}
+	err := json.Unmarshal(data, &payload)
+	return envelope, payload, err
 }
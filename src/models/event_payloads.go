@@ -0,0 +1,62 @@
+package models
+
+import "github.com/google/uuid"
+
+type PlanCreated struct {
+	Plan Plan `json:"plan"`
+}
+
+func (PlanCreated) EventType() string       { return EventTypePlanCreated }
+func (PlanCreated) SchemaVersion() int      { return 1 }
+func (e PlanCreated) ResourceID() uuid.UUID { return e.Plan.ID }
+
+type PlanUpdated struct {
+	Plan Plan `json:"plan"`
+}
+
+func (PlanUpdated) EventType() string       { return EventTypePlanUpdated }
+func (PlanUpdated) SchemaVersion() int      { return 1 }
+func (e PlanUpdated) ResourceID() uuid.UUID { return e.Plan.ID }
+
+// PlanRetired is recorded when a plan is scheduled for retirement. The plan
+// carries the effective date and successor.
+type PlanRetired struct {
+	Plan Plan `json:"plan"`
+}
+
+func (PlanRetired) EventType() string       { return EventTypePlanRetired }
+func (PlanRetired) SchemaVersion() int      { return 1 }
+func (e PlanRetired) ResourceID() uuid.UUID { return e.Plan.ID }
+
+type SubscriptionCreated struct {
+	Subscription Subscription `json:"subscription"`
+}
+
+func (SubscriptionCreated) EventType() string       { return EventTypeSubscriptionCreated }
+func (SubscriptionCreated) SchemaVersion() int      { return 1 }
+func (e SubscriptionCreated) ResourceID() uuid.UUID { return e.Subscription.ID }
+
+type SubscriptionCancelled struct {
+	Subscription Subscription `json:"subscription"`
+}
+
+func (SubscriptionCancelled) EventType() string       { return EventTypeSubscriptionCancelled }
+func (SubscriptionCancelled) SchemaVersion() int      { return 1 }
+func (e SubscriptionCancelled) ResourceID() uuid.UUID { return e.Subscription.ID }
+
+type SubscriptionExpired struct {
+	Subscription Subscription `json:"subscription"`
+}
+
+func (SubscriptionExpired) EventType() string       { return EventTypeSubscriptionExpired }
+func (SubscriptionExpired) SchemaVersion() int      { return 1 }
+func (e SubscriptionExpired) ResourceID() uuid.UUID { return e.Subscription.ID }
+
+type SubscriptionRenewed struct {
+	Subscription           Subscription `json:"subscription"`
+	PreviousSubscriptionID uuid.UUID    `json:"previous_subscription_id"`
+}
+
+func (SubscriptionRenewed) EventType() string       { return EventTypeSubscriptionRenewed }
+func (SubscriptionRenewed) SchemaVersion() int      { return 1 }
+func (e SubscriptionRenewed) ResourceID() uuid.UUID { return e.Subscription.ID }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEventRoundTrip(t *testing.T) {
+	subscription := Subscription{
+		ID:         uuid.New(),
+		CustomerID: uuid.New(),
+		PlanID:     uuid.New(),
+		Status:     SubscriptionStatusActive,
+	}
+	event, err := NewEvent(SubscriptionCreated{Subscription: subscription})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if event.ResourceID != subscription.ID {
+		t.Fatalf("Expected resource id %s, got %s", subscription.ID, event.ResourceID)
+	}
+
+	var wire map[string]json.RawMessage
+	if err := json.Unmarshal(event.Payload, &wire); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	for _, field := range []string{"event_id", "event_type", "schema_version", "producer", "timestamp", "subscription"} {
+		if _, ok := wire[field]; !ok {
+			t.Errorf("Expected field %q in %s", field, event.Payload)
+		}
+	}
+
+	envelope, decoded, err := UnmarshalEvent[SubscriptionCreated](event.Payload)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if envelope.EventID != event.EventID {
+		t.Errorf("Expected event id %s, got %s", event.EventID, envelope.EventID)
+	}
+	if decoded.Subscription.ID != subscription.ID {
+		t.Errorf("Expected subscription %s, got %s", subscription.ID, decoded.Subscription.ID)
+	}
+}
+
+func TestUnmarshalEventRejectsMismatches(t *testing.T) {
+	event, err := NewEvent(PlanCreated{Plan: Plan{ID: uuid.New()}})
+	if err != nil {
+		t.Fatalf("Failed to create event: %v", err)
+	}
+	if _, _, err := UnmarshalEvent[PlanUpdated](event.Payload); err == nil {
+		t.Errorf("Expected error decoding plan.created as plan.updated")
+	}
+
+	future := []byte(`{"event_type": "plan.created", "schema_version": 99}`)
+	if _, _, err := UnmarshalEvent[PlanCreated](future); err == nil {
+		t.Errorf("Expected error decoding an unknown schema version")
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of the first request made with a
+// given Idempotency-Key. Completed is false while that request is running.
+type IdempotencyRecord struct {
+	Key         string `db:"key"`
+	RequestHash string `db:"request_hash"`
+	Completed   bool   `db:"completed"`
+	StatusCode  int    `db:"status_code"`
+	// ResponseHeaders are the stored response's headers, as in http.Header.
+	ResponseHeaders map[string][]string `db:"response_headers"`
+	ResponseBody    []byte              `db:"response_body"`
+	CreatedAt       time.Time           `db:"created_at"`
+	ExpiresAt       time.Time           `db:"expires_at"`
+}
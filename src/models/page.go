@@ -1,11 +1,90 @@
 package models
 
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Page is one page of results. TotalCount and TotalPages are only filled
+// for page/pageSize requests, since counting defeats the point of cursor
+// pagination. NextCursor continues after the last item in (created_at, id)
+// order and is empty on the last page or when results are sorted by other
+// fields.
 type Page[V any] struct {
-	TotalCount int64 `json:"total_count"`
-	Items      []V   `json:"items"`
+	TotalCount *int64 `json:"total_count,omitempty"`
+	Items      []V    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size,omitempty"`
+	TotalPages *int64 `json:"total_pages,omitempty"`
 }
 
+// PageableRequest selects a page either by number or, when Cursor is set,
+// as the PageSize items following the cursor.
 type PageableRequest struct {
-	Page     int `json:"page"`
-	PageSize int `json:"page_size"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+	Cursor   string `json:"cursor,omitempty"`
+}
+
+// Cursor is the keyset position of a row ordered by created_at DESC, id DESC.
+type Cursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// Encode returns the opaque form of c handed to clients.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Compare orders cursors like ORDER BY created_at, id in Postgres, which
+// compares UUIDs byte by byte.
+func (c Cursor) Compare(other Cursor) int {
+	if n := c.CreatedAt.Compare(other.CreatedAt); n != 0 {
+		return n
+	}
+	return bytes.Compare(c.ID[:], other.ID[:])
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID == uuid.Nil {
+		return Cursor{}, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// SortField is one term of a sort=field,-other query parameter.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort splits a comma separated sort parameter into fields, a leading
+// "-" meaning descending. It only checks syntax; each store validates the
+// field names against its own whitelist.
+func ParseSort(sort string) []SortField {
+	var fields []SortField
+	for _, term := range strings.Split(sort, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		field := SortField{Field: term}
+		if rest, ok := strings.CutPrefix(term, "-"); ok {
+			field = SortField{Field: rest, Descending: true}
+		}
+		fields = append(fields, field)
+	}
+	return fields
 }
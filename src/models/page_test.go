@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 11, 3, 10, 30, 0, 123456000, time.UTC), ID: uuid.New()}
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+	for _, invalid := range []string{"", "not base64!", "bm90IGpzb24", Cursor{CreatedAt: cursor.CreatedAt}.Encode()} {
+		if _, err := DecodeCursor(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestCursorCompare(t *testing.T) {
+	now := time.Now()
+	low := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	high := uuid.MustParse("ffffffff-0000-0000-0000-000000000000")
+	testCases := []struct {
+		name string
+		a, b Cursor
+		want int
+	}{
+		{"EarlierTime", Cursor{now, high}, Cursor{now.Add(time.Microsecond), low}, -1},
+		{"SameTimeLowerId", Cursor{now, low}, Cursor{now, high}, -1},
+		{"Equal", Cursor{now, low}, Cursor{now, low}, 0},
+		{"LaterTime", Cursor{now.Add(time.Second), low}, Cursor{now, high}, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.a.Compare(tc.b); got != tc.want {
+				t.Errorf("Expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	fields := ParseSort(" price_cents, -created_at,,")
+	want := []SortField{{Field: "price_cents"}, {Field: "created_at", Descending: true}}
+	if len(fields) != len(want) || fields[0] != want[0] || fields[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want, fields)
+	}
+}
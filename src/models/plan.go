@@ -1,7 +1,10 @@
 package models
 
 import (
+	"bss/src/apperrors"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -17,4 +20,96 @@ type Plan struct {
 	Active       bool      `json:"active" db:"active"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	// Version is the number of the plan's current PlanVersion. It starts at
+	// 1 and grows with every update.
+	Version int `json:"version" db:"version"`
+	// RetiredAt is when the plan stops being sold and its subscribers start
+	// renewing onto SuccessorPlanID, or expiring when there is none.
+	RetiredAt       *time.Time `json:"retired_at,omitempty" db:"retired_at"`
+	SuccessorPlanID *uuid.UUID `json:"successor_plan_id,omitempty" db:"successor_plan_id"`
+}
+
+// PlanVersion is an immutable snapshot of a plan's terms. Subscriptions keep
+// pointing at the version they were bought on when the plan changes.
+type PlanVersion struct {
+	PlanID       uuid.UUID `json:"plan_id" db:"plan_id"`
+	Version      int       `json:"version" db:"version"`
+	Code         string    `json:"code" db:"code"`
+	Name         string    `json:"name" db:"name"`
+	PriceCents   int64     `json:"price_cents" db:"price_cents"`
+	Currency     string    `json:"currency" db:"currency"`
+	DurationDays int       `json:"duration_days" db:"duration_days"`
+	DataMB       int64     `json:"data_mb" db:"data_mb"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// PlanFilter narrows GetPlans. Nil and empty fields do not filter; Sort
+// defaults to newest first.
+type PlanFilter struct {
+	Active          *bool
+	Currency        string
+	MinPriceCents   *int64
+	MaxPriceCents   *int64
+	MinDurationDays *int
+	MaxDurationDays *int
+	CodePrefix      string
+	Name            string
+	Sort            []SortField
+}
+
+// CurrentVersion is the snapshot of p's current terms.
+func (p Plan) CurrentVersion() PlanVersion {
+	return PlanVersion{
+		PlanID:       p.ID,
+		Version:      p.Version,
+		Code:         p.Code,
+		Name:         p.Name,
+		PriceCents:   p.PriceCents,
+		Currency:     p.Currency,
+		DurationDays: p.DurationDays,
+		DataMB:       p.DataMB,
+		CreatedAt:    p.UpdatedAt,
+	}
+}
+
+// Validate checks the client supplied terms of p, reporting every invalid
+// field.
+func (p Plan) Validate() error {
+	var fields apperrors.FieldErrors
+	switch {
+	case p.Code == "":
+		fields.Add("code", "is required")
+	case len(p.Code) > maxPlanCodeLength:
+		fields.Add("code", "must be at most %d characters", maxPlanCodeLength)
+	case !planCodePattern.MatchString(p.Code):
+		fields.Add("code", "must be upper case letters and digits separated by - or _, like BASIC-MONTHLY")
+	}
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		fields.Add("name", "is required")
+	case utf8.RuneCountInString(p.Name) > maxPlanNameLength:
+		fields.Add("name", "must be at most %d characters", maxPlanNameLength)
+	}
+	if p.PriceCents < 0 {
+		fields.Add("price_cents", "must not be negative")
+	}
+	if !IsCurrency(p.Currency) {
+		fields.Add("currency", "must be an ISO 4217 currency code, like USD")
+	}
+	if p.DurationDays <= 0 {
+		fields.Add("duration_days", "must be positive")
+	}
+	if p.DataMB < 0 {
+		fields.Add("data_mb", "must not be negative")
+	}
+	return fields.Err()
+}
+
+// RetiredBy reports whether p's retirement has taken effect at t.
+func (p Plan) RetiredBy(t time.Time) bool {
+	return p.RetiredAt != nil && !t.Before(*p.RetiredAt)
+}
+
+func (p Plan) Cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"bss/src/apperrors"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlanValidate(t *testing.T) {
+	valid := Plan{Code: "RM-UL-30D", Name: "Unlimited 30 Days", PriceCents: 1999, Currency: "USD", DurationDays: 30, DataMB: 30720}
+
+	testCases := []struct {
+		name       string
+		mutate     func(*Plan)
+		wantFields []string
+	}{
+		{"Valid", func(p *Plan) {}, nil},
+		{"FreePlan", func(p *Plan) { p.PriceCents = 0 }, nil},
+		{"UnderscoreCode", func(p *Plan) { p.Code = "BASIC_MONTHLY" }, nil},
+		{"MissingCode", func(p *Plan) { p.Code = "" }, []string{"code"}},
+		{"LowerCaseCode", func(p *Plan) { p.Code = "basic-monthly" }, []string{"code"}},
+		{"CodeWithSpaces", func(p *Plan) { p.Code = "BASIC MONTHLY" }, []string{"code"}},
+		{"CodeWithDoubleHyphen", func(p *Plan) { p.Code = "BASIC--MONTHLY" }, []string{"code"}},
+		{"CodeTooLong", func(p *Plan) { p.Code = strings.Repeat("A", 51) }, []string{"code"}},
+		{"MissingName", func(p *Plan) { p.Name = " " }, []string{"name"}},
+		{"NameTooLong", func(p *Plan) { p.Name = strings.Repeat("é", 256) }, []string{"name"}},
+		{"NegativePrice", func(p *Plan) { p.PriceCents = -1 }, []string{"price_cents"}},
+		{"MissingCurrency", func(p *Plan) { p.Currency = "" }, []string{"currency"}},
+		{"CurrencyWord", func(p *Plan) { p.Currency = "dollars" }, []string{"currency"}},
+		{"LowerCaseCurrency", func(p *Plan) { p.Currency = "usd" }, []string{"currency"}},
+		{"TestingCurrency", func(p *Plan) { p.Currency = "XTS" }, []string{"currency"}},
+		{"ZeroDuration", func(p *Plan) { p.DurationDays = 0 }, []string{"duration_days"}},
+		{"NegativeData", func(p *Plan) { p.DataMB = -1 }, []string{"data_mb"}},
+		{
+			"EveryField",
+			func(p *Plan) { *p = Plan{Code: "basic", PriceCents: -5, Currency: "dollars", DataMB: -1} },
+			[]string{"code", "name", "price_cents", "currency", "duration_days", "data_mb"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := valid
+			tc.mutate(&plan)
+			if got := invalidFields(t, plan.Validate()); !slices.Equal(got, tc.wantFields) {
+				t.Errorf("Expected invalid fields %v, got %v", tc.wantFields, got)
+			}
+		})
+	}
+}
+
+// invalidFields returns the fields reported by a validation error, or nil
+// when err is nil.
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperrors.CodeValidationFailed {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	fields := make([]string, len(appErr.Fields))
+	for i, field := range appErr.Fields {
+		fields[i] = field.Field
+	}
+	return fields
+}
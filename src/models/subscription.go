@@ -1,6 +1,7 @@
 package models
 
 import (
+	"bss/src/apperrors"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,4 +25,44 @@ type Subscription struct {
 	AutoRenew  bool               `json:"auto_renew" db:"auto_renew"`
 	CreatedAt  time.Time          `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time          `json:"updated_at" db:"updated_at"`
+	// RenewedFrom is the subscription whose period this one continues, set
+	// only on subscriptions created by auto-renewal.
+	RenewedFrom *uuid.UUID `json:"renewed_from,omitempty" db:"renewed_from"`
+	// PlanVersion is the version of the plan the subscription was bought on.
+	PlanVersion int `json:"plan_version" db:"plan_version"`
+}
+
+// Validate checks the invariants of a subscription about to be stored,
+// reporting every invalid field.
+func (s Subscription) Validate() error {
+	var fields apperrors.FieldErrors
+	if s.CustomerID == uuid.Nil {
+		fields.Add("customer_id", "is required")
+	}
+	if s.PlanID == uuid.Nil {
+		fields.Add("plan_id", "is required")
+	}
+	if s.PlanVersion < 0 {
+		fields.Add("plan_version", "must not be negative")
+	}
+	switch s.Status {
+	case SubscriptionStatusActive, SubscriptionStatusCancelled, SubscriptionStatusExpired:
+	default:
+		fields.Add("status", "must be one of ACTIVE, CANCELLED or EXPIRED")
+	}
+	if !s.EndDate.After(s.StartDate) {
+		fields.Add("end_date", "must be after start_date")
+	}
+	return fields.Err()
+}
+
+// PlanSubscriptionCount is the number of active subscriptions on a plan.
+type PlanSubscriptionCount struct {
+	PlanID   uuid.UUID `json:"plan_id" db:"plan_id"`
+	PlanCode string    `json:"plan_code" db:"plan_code"`
+	Active   int64     `json:"active" db:"active"`
+}
+
+func (s Subscription) Cursor() Cursor {
+	return Cursor{CreatedAt: s.CreatedAt, ID: s.ID}
 }
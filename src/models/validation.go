@@ -0,0 +1,129 @@
+package models
+
+import (
+	"bss/src/apperrors"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// planCodePattern is the catalog's code format: upper case letters and
+// digits in groups separated by single hyphens or underscores, like
+// BASIC-MONTHLY.
+var planCodePattern = regexp.MustCompile(`^[A-Z0-9]+([-_][A-Z0-9]+)*$`)
+
+const (
+	maxPlanCodeLength = 50
+	maxPlanNameLength = 255
+)
+
+// currencies holds the ISO 4217 codes of circulating currencies. Fund codes,
+// precious metals and testing codes are left out.
+var currencies = func() map[string]bool {
+	codes := strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+		BOB BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF
+		DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD
+		HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW
+		KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR
+		MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN
+		PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN
+		SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VED
+		VES VND VUV WST XAF XCD XCG XOF XPF YER ZAR ZMW ZWG`)
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}()
+
+// IsCurrency reports whether code is an ISO 4217 currency code.
+func IsCurrency(code string) bool {
+	return currencies[code]
+}
+
+// DecodeJSON decodes the single JSON object in r into v. Malformed JSON is a
+// validation error; unknown fields and fields of the wrong type are reported
+// all at once, each naming its field, and the other fields are still decoded
+// into v.
+func DecodeJSON(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil {
+		return apperrors.Validation("malformed JSON body: %v", err)
+	} else if token != json.Delim('{') {
+		return apperrors.Validation("malformed JSON body: expected a JSON object")
+	}
+	var fields apperrors.FieldErrors
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return apperrors.Validation("malformed JSON body: %v", err)
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return apperrors.Validation("malformed JSON body: %v", err)
+		}
+		if err := decodeMember(key, value, v); err != nil {
+			fields = append(fields, *err)
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return apperrors.Validation("malformed JSON body: %v", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return apperrors.Validation("malformed JSON body: unexpected data after the JSON value")
+	}
+	return fields.Err()
+}
+
+// decodeMember decodes a single member of a JSON object into v, so that
+// encoding/json still matches it to a struct field.
+func decodeMember(key string, value json.RawMessage, v any) *apperrors.FieldError {
+	name, _ := json.Marshal(key)
+	member := append(append(append([]byte{'{'}, name...), ':'), value...)
+	decoder := json.NewDecoder(bytes.NewReader(append(member, '}')))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &apperrors.FieldError{Field: typeErr.Field, Message: "must be " + jsonType(typeErr.Type)}
+	}
+	// encoding/json has no typed error for unknown fields.
+	if _, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &apperrors.FieldError{Field: key, Message: "is not a known field"}
+	}
+	return &apperrors.FieldError{Field: key, Message: "is invalid"}
+}
+
+// jsonType describes the JSON value expected for a Go type.
+func jsonType(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		// Such as uuid.UUID, which is an array in Go but a string in JSON.
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "an object"
+}
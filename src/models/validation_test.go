@@ -0,0 +1,51 @@
+package models
+
+import (
+	"bss/src/apperrors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantErr    bool
+		wantFields []string
+	}{
+		{"Valid", `{"code": "BASIC-30", "price_cents": 999}`, false, nil},
+		{"UnknownField", `{"code": "BASIC-30", "colour": "red"}`, true, []string{"colour"}},
+		{"WrongType", `{"price_cents": "cheap"}`, true, []string{"price_cents"}},
+		{"WrongTypeUUID", `{"successor_plan_id": 5}`, true, []string{"successor_plan_id"}},
+		{"EveryInvalidField", `{"colour": "red", "price_cents": "cheap", "size": 1}`, true, []string{"colour", "price_cents", "size"}},
+		{"NotAnObject", `["BASIC-30"]`, true, nil},
+		{"Malformed", `{"code": `, true, nil},
+		{"TrailingData", `{"code": "BASIC-30"} {}`, true, nil},
+		{"Empty", ``, true, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var plan Plan
+			err := DecodeJSON(strings.NewReader(tc.body), &plan)
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if plan.Code != "BASIC-30" || plan.PriceCents != 999 {
+					t.Errorf("Unexpected plan %+v", plan)
+				}
+				return
+			}
+			if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+			if got := invalidFields(t, err); len(got) > 0 || len(tc.wantFields) > 0 {
+				if !slices.Equal(got, tc.wantFields) {
+					t.Errorf("Expected invalid fields %v, got %v", tc.wantFields, got)
+				}
+			}
+		})
+	}
+}
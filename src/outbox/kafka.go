@@ -0,0 +1,62 @@
+package outbox
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes events to Kafka, routing each one to the topic
+// returned by TopicFor and keying it by resource id so that events for the
+// same plan or subscription stay ordered within a partition.
+type KafkaPublisher struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+// NewKafkaPublisher creates a publisher for a comma separated broker list, as
+// found in the KAFKA_BROKERS environment variable.
+func NewKafkaPublisher(brokers string) *KafkaPublisher {
+	addrs := strings.Split(brokers, ",")
+	return &KafkaPublisher{
+		brokers: addrs,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(addrs...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+			BatchTimeout:           10 * time.Millisecond,
+		},
+	}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic: TopicFor(event.EventType),
+		Key:   []byte(event.ResourceID.String()),
+		Value: event.Payload,
+		Headers: []kafka.Header{
+			{Key: "event_id", Value: []byte(event.EventID.String())},
+			{Key: "event_type", Value: []byte(event.EventType)},
+		},
+	})
+}
+
+// Ping succeeds once any broker accepts a connection, which is all the
+// writer needs to discover the rest of the cluster.
+func (p *KafkaPublisher) Ping(ctx context.Context) error {
+	var err error
+	for _, broker := range p.brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err == nil {
+			return conn.Close()
+		}
+	}
+	return err
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,35 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher keeps published events in memory. It is meant for tests and
+// local runs without a broker.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns a copy of everything published so far, in publish order.
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event(nil), p.events...)
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
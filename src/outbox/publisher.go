@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"bss/src/models"
+	"context"
+	"strings"
+)
+
+type Event = models.Event
+
+// Publisher delivers a single outbox event to a message broker. Publish must
+// only return nil once the broker has accepted the event.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// TopicFor maps an event type such as "plan.created" to the topic carrying
+// that resource's lifecycle events, e.g. "plan.events".
+func TopicFor(eventType string) string {
+	resource, _, _ := strings.Cut(eventType, ".")
+	return resource + ".events"
+}
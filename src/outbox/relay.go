@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Store is the part of the database the relay needs. PublishPendingEvents
+// must dead-letter an event, i.e. stop handing it out, once publishing it
+// has failed maxAttempts times.
+type Store interface {
+	PublishPendingEvents(ctx context.Context, limit int, maxAttempts int, publish func(context.Context, Event) error) (int, error)
+}
+
+// Relay moves events from the outbox table to a Publisher. Events are only
+// marked as published after the publisher accepts them, so delivery is
+// at-least-once: a crash between the two steps republishes the event. An
+// event the broker rejects MaxAttempts times in a row is dead-lettered and
+// skipped, so that it cannot block the outbox forever.
+type Relay struct {
+	store     Store
+	publisher Publisher
+
+	BatchSize      int
+	PollInterval   time.Duration
+	MinBackoff     time.Duration
+	MaxBackoff     time.Duration
+	MaxAttempts    int
+	PublishTimeout time.Duration
+}
+
+func NewRelay(store Store, publisher Publisher) *Relay {
+	return &Relay{
+		store:          store,
+		publisher:      publisher,
+		BatchSize:      100,
+		PollInterval:   time.Second,
+		MinBackoff:     time.Second,
+		MaxBackoff:     time.Minute,
+		MaxAttempts:    10,
+		PublishTimeout: 10 * time.Second,
+	}
+}
+
+// Run polls for unpublished events until ctx is cancelled. Failed batches are
+// retried with exponential backoff between MinBackoff and MaxBackoff.
+func (r *Relay) Run(ctx context.Context) {
+	backoff := time.Duration(0)
+	for {
+		n, err := r.publishBatch(ctx)
+		wait := r.PollInterval
+		switch {
+		case err != nil:
+			backoff = r.nextBackoff(backoff)
+			wait = backoff
+			log.Printf("outbox relay: publish failed after %d events, retrying in %s: %v", n, wait, err)
+		case n == r.BatchSize:
+			backoff = 0
+			wait = 0
+		default:
+			backoff = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (r *Relay) publishBatch(ctx context.Context) (int, error) {
+	return r.store.PublishPendingEvents(ctx, r.BatchSize, r.MaxAttempts, r.publish)
+}
+
+// publish bounds each broker call by PublishTimeout, so that a hung broker
+// counts as a failed attempt instead of stalling the relay.
+func (r *Relay) publish(ctx context.Context, event Event) error {
+	ctx, cancel := context.WithTimeout(ctx, r.PublishTimeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, event)
+}
+
+func (r *Relay) nextBackoff(current time.Duration) time.Duration {
+	if current < r.MinBackoff {
+		return r.MinBackoff
+	}
+	next := current * 2
+	if next > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return next
+}
@@ -0,0 +1,210 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeStore struct {
+	mu           sync.Mutex
+	events       []Event
+	published    map[int64]bool
+	attempts     map[int64]int
+	deadLettered map[int64]bool
+}
+
+func newFakeStore(n int) *fakeStore {
+	store := &fakeStore{published: map[int64]bool{}, attempts: map[int64]int{}, deadLettered: map[int64]bool{}}
+	for i := 1; i <= n; i++ {
+		store.events = append(store.events, Event{
+			ID:         int64(i),
+			EventType:  "plan.created",
+			ResourceID: uuid.New(),
+		})
+	}
+	return store
+}
+
+func (s *fakeStore) PublishPendingEvents(ctx context.Context, limit int, maxAttempts int, publish func(context.Context, Event) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, event := range s.events {
+		if count == limit {
+			break
+		}
+		if s.published[event.ID] || s.deadLettered[event.ID] {
+			continue
+		}
+		if err := publish(ctx, event); err != nil {
+			s.attempts[event.ID]++
+			s.deadLettered[event.ID] = s.attempts[event.ID] >= maxAttempts
+			return count, err
+		}
+		s.published[event.ID] = true
+		count++
+	}
+	return count, nil
+}
+
+func (s *fakeStore) isDeadLettered(id int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deadLettered[id]
+}
+
+// rejectingPublisher permanently rejects one event, like a broker refusing
+// a message that is too large.
+type rejectingPublisher struct {
+	*MemoryPublisher
+	reject int64
+}
+
+func (p *rejectingPublisher) Publish(ctx context.Context, event Event) error {
+	if event.ID == p.reject {
+		return errors.New("message too large")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+// hangingPublisher blocks on its first call until ctx is done.
+type hangingPublisher struct {
+	*MemoryPublisher
+	once sync.Once
+}
+
+func (p *hangingPublisher) Publish(ctx context.Context, event Event) error {
+	hang := false
+	p.once.Do(func() { hang = true })
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+type flakyPublisher struct {
+	*MemoryPublisher
+	mu       sync.Mutex
+	failures int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	if p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return errors.New("broker unavailable")
+	}
+	p.mu.Unlock()
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func runRelayUntil(t *testing.T, relay *Relay, done func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		relay.Run(ctx)
+		close(finished)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("relay did not publish all events in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-finished
+}
+
+func TestRelayPublishesAllEventsInOrder(t *testing.T) {
+	store := newFakeStore(25)
+	publisher := NewMemoryPublisher()
+	relay := NewRelay(store, publisher)
+	relay.BatchSize = 10
+	relay.PollInterval = time.Millisecond
+
+	runRelayUntil(t, relay, func() bool { return len(publisher.Events()) == 25 })
+
+	for i, event := range publisher.Events() {
+		if event.ID != int64(i+1) {
+			t.Fatalf("Expected event %d at position %d, got %d", i+1, i, event.ID)
+		}
+	}
+}
+
+func TestRelayRetriesAfterPublishFailure(t *testing.T) {
+	store := newFakeStore(3)
+	publisher := &flakyPublisher{MemoryPublisher: NewMemoryPublisher(), failures: 2}
+	relay := NewRelay(store, publisher)
+	relay.PollInterval = time.Millisecond
+	relay.MinBackoff = time.Millisecond
+	relay.MaxBackoff = 4 * time.Millisecond
+
+	runRelayUntil(t, relay, func() bool { return len(publisher.Events()) == 3 })
+}
+
+func TestRelayDeadLettersRejectedEvent(t *testing.T) {
+	store := newFakeStore(3)
+	publisher := &rejectingPublisher{MemoryPublisher: NewMemoryPublisher(), reject: 2}
+	relay := NewRelay(store, publisher)
+	relay.PollInterval = time.Millisecond
+	relay.MinBackoff = time.Millisecond
+	relay.MaxBackoff = time.Millisecond
+	relay.MaxAttempts = 3
+
+	runRelayUntil(t, relay, func() bool { return len(publisher.Events()) == 2 })
+
+	if !store.isDeadLettered(2) {
+		t.Errorf("Expected event 2 to be dead-lettered")
+	}
+	if store.attempts[2] != 3 {
+		t.Errorf("Expected 3 attempts on event 2, got %d", store.attempts[2])
+	}
+	if events := publisher.Events(); events[0].ID != 1 || events[1].ID != 3 {
+		t.Errorf("Expected events 1 and 3 to be published, got %+v", events)
+	}
+}
+
+func TestRelayPublishTimeout(t *testing.T) {
+	store := newFakeStore(2)
+	publisher := &hangingPublisher{MemoryPublisher: NewMemoryPublisher()}
+	relay := NewRelay(store, publisher)
+	relay.PollInterval = time.Millisecond
+	relay.MinBackoff = time.Millisecond
+	relay.PublishTimeout = 10 * time.Millisecond
+
+	runRelayUntil(t, relay, func() bool { return len(publisher.Events()) == 2 })
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(nil, nil)
+	relay.MinBackoff = time.Second
+	relay.MaxBackoff = 5 * time.Second
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	backoff := time.Duration(0)
+	for _, want := range expected {
+		backoff = relay.nextBackoff(backoff)
+		if backoff != want {
+			t.Fatalf("Expected backoff %s, got %s", want, backoff)
+		}
+	}
+}
+
+func TestTopicFor(t *testing.T) {
+	if topic := TopicFor("subscription.cancelled"); topic != "subscription.events" {
+		t.Fatalf("Expected subscription.events, got %s", topic)
+	}
+	if topic := TopicFor("plan.created"); topic != "plan.events" {
+		t.Fatalf("Expected plan.events, got %s", topic)
+	}
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"bss/src/models"
+	"context"
+	"log"
+	"time"
+)
+
+type ExpiryStore interface {
+	ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error)
+}
+
+// ExpiryJob moves ACTIVE subscriptions past their end date to EXPIRED. Each
+// run keeps sweeping in batches of batchSize until nothing is left due.
+func ExpiryJob(store ExpiryStore, interval time.Duration, batchSize int) Job {
+	return Job{
+		Name:     "subscription-expiry",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			total := 0
+			for {
+				expired, err := store.ExpireSubscriptions(ctx, now, batchSize)
+				if err != nil {
+					return err
+				}
+				total += len(expired)
+				if len(expired) < batchSize {
+					break
+				}
+			}
+			if total > 0 {
+				log.Printf("scheduler: expired %d subscriptions", total)
+			}
+			return nil
+		},
+	}
+}
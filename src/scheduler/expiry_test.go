@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"bss/src/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fakeExpiryStore struct {
+	due   int
+	calls []int
+}
+
+func (s *fakeExpiryStore) ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	n := min(s.due, limit)
+	s.due -= n
+	s.calls = append(s.calls, n)
+	expired := make([]models.Subscription, n)
+	for i := range expired {
+		expired[i] = models.Subscription{ID: uuid.New(), Status: models.SubscriptionStatusExpired}
+	}
+	return expired, nil
+}
+
+func TestExpiryJobDrainsAllBatches(t *testing.T) {
+	store := &fakeExpiryStore{due: 25}
+	job := ExpiryJob(store, time.Minute, 10)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Expiry job failed: %v", err)
+	}
+	if store.due != 0 {
+		t.Fatalf("Expected all subscriptions to be expired, %d left", store.due)
+	}
+	if len(store.calls) != 3 {
+		t.Fatalf("Expected 3 batches, got %v", store.calls)
+	}
+}
+
+func TestSchedulerRunsJobsUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 10)
+	scheduler := New(Job{
+		Name:     "test",
+		Interval: time.Millisecond,
+		Run: func(ctx context.Context) error {
+			select {
+			case runs <- struct{}{}:
+			default:
+			}
+			return nil
+		},
+	})
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatal("Job was not run")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Scheduler did not stop after cancel")
+	}
+}
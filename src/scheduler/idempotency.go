@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+type IdempotencyStore interface {
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+// IdempotencyCleanupJob purges stored Idempotency-Key responses past their TTL.
+func IdempotencyCleanupJob(store IdempotencyStore, interval time.Duration) Job {
+	return Job{
+		Name:     "idempotency-cleanup",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			_, err := store.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+			return err
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"bss/src/models"
+	"context"
+	"log"
+	"time"
+)
+
+type RenewalStore interface {
+	// RenewSubscriptions handles up to limit due subscriptions, returning
+	// the renewals it opened and how many due subscriptions it took off the
+	// queue, including ones expired instead of renewed.
+	RenewSubscriptions(ctx context.Context, now time.Time, limit int) (renewed []models.Subscription, processed int, err error)
+}
+
+// RenewalJob opens the next period for due auto-renew subscriptions. Like
+// ExpiryJob it keeps sweeping in batches until a batch comes back short of
+// due subscriptions; renewals that are themselves already past due are
+// picked up by the next batch.
+func RenewalJob(store RenewalStore, interval time.Duration, batchSize int) Job {
+	return Job{
+		Name:     "subscription-renewal",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			total := 0
+			for {
+				renewed, processed, err := store.RenewSubscriptions(ctx, now, batchSize)
+				if err != nil {
+					return err
+				}
+				total += len(renewed)
+				if processed < batchSize {
+					break
+				}
+			}
+			if total > 0 {
+				log.Printf("scheduler: renewed %d subscriptions", total)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"bss/src/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeRenewalStore has due subscriptions none of which can be renewed, like
+// subscribers of retired plans that are expired instead.
+type fakeRenewalStore struct {
+	due   int
+	calls []int
+}
+
+func (s *fakeRenewalStore) RenewSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, int, error) {
+	n := min(s.due, limit)
+	s.due -= n
+	s.calls = append(s.calls, n)
+	return nil, n, nil
+}
+
+func TestRenewalJobDrainsBatchesWithoutRenewals(t *testing.T) {
+	store := &fakeRenewalStore{due: 25}
+	job := RenewalJob(store, time.Minute, 10)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Renewal job failed: %v", err)
+	}
+	if store.due != 0 {
+		t.Fatalf("Expected every due subscription to be processed, %d left", store.due)
+	}
+	if len(store.calls) != 3 {
+		t.Fatalf("Expected 3 batches, got %v", store.calls)
+	}
+}
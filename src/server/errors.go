@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+)
+
+type errorBody struct {
+	Code    apperrors.Code         `json:"code"`
+	Message string                 `json:"message"`
+	Fields  []apperrors.FieldError `json:"fields,omitempty"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+func statusForCode(code apperrors.Code) int {
+	switch code {
+	case apperrors.CodeValidationFailed:
+		return http.StatusBadRequest
+	case apperrors.CodePlanNotFound, apperrors.CodeSubscriptionNotFound:
+		return http.StatusNotFound
+	case apperrors.CodePlanAlreadyExists, apperrors.CodeSubscriptionAlreadyExists, apperrors.CodeIdempotencyKeyInProgress:
+		return http.StatusConflict
+	case apperrors.CodeIdempotencyKeyReused:
+		return http.StatusUnprocessableEntity
+	case apperrors.CodePlanModified:
+		return http.StatusPreconditionFailed
+	case apperrors.CodeRequestTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperrors.CodeServiceUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError writes err as {"error": {"code", "message", "fields"}} with the
+// status matching its code. Errors that are not *apperrors.Error are logged
+// and reported as a generic INTERNAL_ERROR so driver messages never reach
+// clients.
+func writeError(w http.ResponseWriter, err error) {
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		log.Printf("internal error: %v", err)
+		appErr = apperrors.New(apperrors.CodeInternal, "internal server error")
+	} else if appErr.Err != nil {
+		log.Printf("%v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusForCode(appErr.Code))
+	json.NewEncoder(w).Encode(errorResponse{
+		Error: errorBody{Code: appErr.Code, Message: appErr.Message, Fields: appErr.Fields},
+	})
+}
+
+// maxRequestBodyBytes bounds the request bodies read into memory.
+const maxRequestBodyBytes = 1 << 20
+
+// readBody reads the whole request body, failing with REQUEST_TOO_LARGE
+// past maxRequestBodyBytes.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, apperrors.ErrRequestTooLarge
+	}
+	if err != nil {
+		return nil, apperrors.Validation("failed to read request body")
+	}
+	return body, nil
+}
+
+// decodeJSON decodes the request body into v, reporting malformed bodies and
+// unknown fields as validation errors and bodies past maxRequestBodyBytes as
+// REQUEST_TOO_LARGE. When some fields fail to decode,
+// validate still runs on the rest of v, so that one response lists every
+// invalid field.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, validate ...func() error) error {
+	body, err := readBody(w, r)
+	if err != nil {
+		return err
+	}
+	err = models.DecodeJSON(bytes.NewReader(body), v)
+	fields := apperrors.FieldsOf(err)
+	if len(fields) == 0 {
+		return err
+	}
+	for _, fn := range validate {
+		for _, field := range apperrors.FieldsOf(fn()) {
+			// A field that failed to decode is left zero, which says
+			// nothing about what the client sent.
+			if !slices.ContainsFunc(fields, func(f apperrors.FieldError) bool { return f.Field == field.Field }) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields.Err()
+}
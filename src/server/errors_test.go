@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"Validation", apperrors.Validation("invalid plan id"), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"PlanNotFound", apperrors.ErrPlanNotFound, http.StatusNotFound, apperrors.CodePlanNotFound},
+		{"WrappedConflict", fmt.Errorf("subscribe: %w", apperrors.ErrSubscriptionAlreadyExists), http.StatusConflict, apperrors.CodeSubscriptionAlreadyExists},
+		{"Unavailable", apperrors.Wrap(apperrors.CodeServiceUnavailable, "database unavailable", errors.New("dial tcp")), http.StatusServiceUnavailable, apperrors.CodeServiceUnavailable},
+		{"Unknown", errors.New(`ERROR: invalid input syntax for type uuid: "abc" (SQLSTATE 22P02)`), http.StatusInternalServerError, apperrors.CodeInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			writeError(recorder, tc.err)
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("Expected status %d, got %d", tc.wantStatus, recorder.Code)
+			}
+			var body errorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode error body %q: %v", recorder.Body.String(), err)
+			}
+			if body.Error.Code != tc.wantCode {
+				t.Errorf("Expected code %s, got %s", tc.wantCode, body.Error.Code)
+			}
+			if strings.Contains(body.Error.Message, "SQLSTATE") || strings.Contains(body.Error.Message, "dial tcp") {
+				t.Errorf("Error message leaks internals: %q", body.Error.Message)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyTooLarge(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	body := `{"name": "` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"CreatePlan", http.MethodPost, "/plans"},
+		{"UpdatePlan", http.MethodPut, "/plans/" + plan.ID.String()},
+		{"RetirePlan", http.MethodPost, "/plans/" + plan.ID.String() + "/retire"},
+		{"Subscribe", http.MethodPost, subscribePath(plan.ID.String())},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, tc.method, tc.path, body)
+			expectStatus(t, recorder, http.StatusRequestEntityTooLarge)
+			expectErrorCode(t, recorder, apperrors.CodeRequestTooLarge)
+		})
+	}
+}
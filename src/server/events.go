@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+
+	// Streams are read row by row rather than buffered, so they may be
+	// much larger than a JSON page, but are still bounded so one request
+	// cannot hold a connection for an arbitrarily long replay.
+	defaultStreamedEventsLimit = 10000
+	maxStreamedEventsLimit     = 100000
+)
+
+type eventsResponse struct {
+	Items       []Event `json:"items"`
+	NextAfterID int64   `json:"next_after_id"`
+}
+
+func (s *Server) setupEventRoutes() {
+	s.router.Get("/events", s.handleGetEvents)
+}
+
+// handleGetEvents replays the event log after the after_id cursor. Clients
+// asking for application/x-ndjson (or passing format=ndjson) get one event
+// per line, streamed as it is read, and continue from the last id they
+// received; everyone else gets a smaller JSON page plus the cursor to
+// continue from.
+func (s *Server) handleGetEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter EventFilter
+	if afterIdStr := query.Get("after_id"); afterIdStr != "" {
+		afterId, err := strconv.ParseInt(afterIdStr, 10, 64)
+		if err != nil || afterId < 0 {
+			writeError(w, apperrors.Validation("invalid after_id"))
+			return
+		}
+		filter.AfterID = afterId
+	}
+	filter.EventType = query.Get("type")
+	if resourceIdStr := query.Get("resource_id"); resourceIdStr != "" {
+		resourceId, err := uuid.Parse(resourceIdStr)
+		if err != nil {
+			writeError(w, apperrors.Validation("invalid resource_id"))
+			return
+		}
+		filter.ResourceID = &resourceId
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			writeError(w, apperrors.Validation("invalid limit"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if query.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		if filter.Limit == 0 {
+			filter.Limit = defaultStreamedEventsLimit
+		}
+		filter.Limit = min(filter.Limit, maxStreamedEventsLimit)
+		s.streamEvents(w, r, filter)
+		return
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultEventsLimit
+	}
+	filter.Limit = min(filter.Limit, maxEventsLimit)
+	response := eventsResponse{Items: []Event{}, NextAfterID: filter.AfterID}
+	err := s.events.StreamEvents(r.Context(), filter, func(event Event) error {
+		response.Items = append(response.Items, event)
+		response.NextAfterID = event.ID
+		return nil
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, filter EventFilter) {
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	started := false
+	err := s.events.StreamEvents(r.Context(), filter, func(event Event) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		// The 200 and some events are already out. Abort the connection
+		// instead of ending the chunked body cleanly, so that the client
+		// sees a broken stream rather than a complete catch-up.
+		log.Printf("events stream after id %d failed: %v", filter.AfterID, err)
+		panic(http.ErrAbortHandler)
+	}
+	if !started {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
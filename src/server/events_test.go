@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bss/src/database/memory"
+	"bss/src/service"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamEventsLimit(t *testing.T) {
+	s, _ := newTestServer(t)
+	for i := range 3 {
+		createPlan(t, s, fmt.Sprintf(`{"code": "BASIC-%d", "name": "Basic", "price_cents": 999, "currency": "USD", "duration_days": 30, "data_mb": 5120}`, i))
+	}
+
+	testCases := []struct {
+		name      string
+		query     string
+		wantLines int
+	}{
+		{"DefaultLimit", "", 3},
+		{"ExplicitLimit", "?limit=2", 2},
+		{"AboveMaximum", fmt.Sprintf("?limit=%d", maxStreamedEventsLimit+1), 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events"+tc.query, nil)
+			req.Header.Set("Accept", "application/x-ndjson")
+			recorder := httptest.NewRecorder()
+			s.router.ServeHTTP(recorder, req)
+			expectStatus(t, recorder, http.StatusOK)
+			if lines := strings.Count(recorder.Body.String(), "\n"); lines != tc.wantLines {
+				t.Errorf("Expected %d events, got %d: %s", tc.wantLines, lines, recorder.Body.String())
+			}
+		})
+	}
+}
+
+// failingEventReader streams its events and then fails, like a database
+// connection dropped mid-stream.
+type failingEventReader struct {
+	events []Event
+}
+
+func (f failingEventReader) StreamEvents(ctx context.Context, filter EventFilter, fn func(Event) error) error {
+	for _, event := range f.events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return errors.New("conn closed")
+}
+
+func TestStreamEventsAbortsOnError(t *testing.T) {
+	db := memory.New()
+	events := failingEventReader{events: []Event{{ID: 1, EventType: "plan.created"}}}
+	s := NewServer(service.NewPlanService(db), service.NewSubscriptionService(db), events)
+	httpServer := httptest.NewServer(s.Handler())
+	defer httpServer.Close()
+
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/events", nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the stream to start with 200, got %d", resp.StatusCode)
+	}
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Errorf("Expected a truncated stream to fail to read, not to end cleanly")
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bss/src/health"
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) setupHealthRoutes() {
+	s.router.Get("/healthz", s.handleHealthz)
+	s.router.Get("/readyz", s.handleReadyz)
+}
+
+// handleHealthz is the liveness probe. It answers 200 as long as the process
+// can serve requests and runs no dependency checks, since restarting the
+// process would not fix a dependency outage.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, http.StatusOK, health.Report{Status: health.StatusOK})
+}
+
+// handleReadyz is the readiness probe: 503 while shutting down or while any
+// dependency check fails.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Run(r.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealthReport(w, status, report)
+}
+
+func writeHealthReport(w http.ResponseWriter, status int, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bss/src/database/memory"
+	"bss/src/health"
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	failing := func(ctx context.Context) error {
+		return errors.New("failed to connect to `user=postgres database=bss`: 10.0.0.5:5432")
+	}
+
+	testCases := []struct {
+		name       string
+		check      health.Check
+		shutdown   bool
+		wantReadyz int
+		wantStatus health.Status
+		wantError  string
+	}{
+		{
+			name:       "Healthy",
+			check:      func(ctx context.Context) error { return nil },
+			wantReadyz: http.StatusOK,
+			wantStatus: health.StatusOK,
+		},
+		{
+			name:       "DependencyDown",
+			check:      failing,
+			wantReadyz: http.StatusServiceUnavailable,
+			wantStatus: health.StatusDegraded,
+			wantError:  "failed",
+		},
+		{
+			name:       "ShuttingDown",
+			check:      func(ctx context.Context) error { return nil },
+			shutdown:   true,
+			wantReadyz: http.StatusServiceUnavailable,
+			wantStatus: health.StatusShuttingDown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			registry := health.NewRegistry()
+			registry.Register("database", time.Second, func(ctx context.Context) error {
+				calls.Add(1)
+				return tc.check(ctx)
+			})
+			s := newServer(memory.New(), WithHealth(registry))
+			if tc.shutdown {
+				registry.Shutdown()
+			}
+
+			recorder := do(t, s, http.MethodGet, "/healthz", "")
+			expectStatus(t, recorder, http.StatusOK)
+			if report := decode[health.Report](t, recorder); report.Status != health.StatusOK || len(report.Checks) != 0 {
+				t.Errorf("Expected a bare ok from /healthz, got %+v", report)
+			}
+			if calls.Load() != 0 {
+				t.Errorf("Expected /healthz not to run dependency checks")
+			}
+
+			recorder = do(t, s, http.MethodGet, "/readyz", "")
+			expectStatus(t, recorder, tc.wantReadyz)
+			report := decode[health.Report](t, recorder)
+			if report.Status != tc.wantStatus {
+				t.Errorf("Expected status %s, got %s", tc.wantStatus, report.Status)
+			}
+			check, ok := report.Checks["database"]
+			if !ok {
+				t.Fatalf("Expected the database check in %+v", report.Checks)
+			}
+			if check.Error != tc.wantError {
+				t.Errorf("Expected error %q, got %q", tc.wantError, check.Error)
+			}
+		})
+	}
+}
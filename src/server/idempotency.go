@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+// unstoredHeaders are left out of stored responses: hop-by-hop headers, and
+// ones net/http sets afresh on every response.
+var unstoredHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length", "Date",
+}
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key string, requestHash string, ttl time.Duration) (IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, headers map[string][]string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// recordingWriter passes the response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+"\n"+r.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotent makes a POST handler safe to retry. The first request with a
+// given Idempotency-Key on an endpoint runs normally and its response,
+// headers included, is stored; retries with the same key and body get the
+// stored response back, while reusing the key for a different request is
+// rejected with 422. Responses with a 5xx status are not stored, so the
+// client can retry them for real. Requests without the header are passed
+// through untouched.
+func (s *Server) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.idempotencyStore == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeError(w, apperrors.Validation("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen))
+			return
+		}
+		body, err := readBody(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(r, body)
+		// Keys are scoped to the endpoint, so that one key sent to two
+		// endpoints is two independent requests.
+		key = r.Method + " " + r.URL.Path + " " + key
+
+		record, reserved, err := s.idempotencyStore.ReserveIdempotencyKey(r.Context(), key, hash, s.idempotencyTTL)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case record.RequestHash != hash:
+				writeError(w, apperrors.ErrIdempotencyKeyReused)
+			case !record.Completed:
+				writeError(w, apperrors.ErrIdempotencyKeyInProgress)
+			default:
+				for name, values := range record.ResponseHeaders {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				s.idempotencyStore.ReleaseIdempotencyKey(context.WithoutCancel(r.Context()), key)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+
+		// The response has already been sent, so store the outcome even if
+		// the client has gone away in the meantime.
+		ctx := context.WithoutCancel(r.Context())
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+			err = s.idempotencyStore.ReleaseIdempotencyKey(ctx, key)
+		} else {
+			headers := w.Header().Clone()
+			for _, name := range unstoredHeaders {
+				headers.Del(name)
+			}
+			err = s.idempotencyStore.CompleteIdempotencyKey(ctx, key, recorder.status, headers, recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("failed to store idempotent response for key %q: %v", key, err)
+		}
+	})
+}
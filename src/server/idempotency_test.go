@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{records: map[string]IdempotencyRecord{}}
+}
+
+func (f *fakeIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, key string, requestHash string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if record, ok := f.records[key]; ok && record.ExpiresAt.After(time.Now()) {
+		return record, false, nil
+	}
+	record := IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: time.Now().Add(ttl)}
+	f.records[key] = record
+	return record, true, nil
+}
+
+func (f *fakeIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, headers map[string][]string, body []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := f.records[key]
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ResponseHeaders = headers
+	record.ResponseBody = append([]byte(nil), body...)
+	f.records[key] = record
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.records, key)
+	return nil
+}
+
+func TestIdempotentMiddleware(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	s := &Server{idempotencyStore: store, idempotencyTTL: time.Hour}
+	calls := 0
+	status := http.StatusCreated
+	handler := s.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, calls))
+		w.Header().Set("Location", "/plans/1")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call": %d}`, calls)
+	}))
+
+	sendTo := func(path string, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		return sendTo("/plans", key, body)
+	}
+
+	first := send("key-1", `{"code": "A"}`)
+	if first.Code != http.StatusCreated || calls != 1 {
+		t.Fatalf("Expected first request to run, got status %d after %d calls", first.Code, calls)
+	}
+
+	replay := send("key-1", `{"code": "A"}`)
+	if calls != 1 {
+		t.Fatalf("Expected replay not to run the handler, got %d calls", calls)
+	}
+	if replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Fatalf("Expected replayed response %d %q, got %d %q", first.Code, first.Body.String(), replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected Idempotent-Replayed header on replay")
+	}
+	for _, name := range []string{"Content-Type", "ETag", "Location"} {
+		if got, want := replay.Header().Get(name), first.Header().Get(name); got != want {
+			t.Errorf("Expected replayed %s %q, got %q", name, want, got)
+		}
+	}
+
+	if other := sendTo("/customers/1/subscribe", "key-1", `{"code": "A"}`); other.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("Expected the key to be independent on another endpoint, got status %d after %d calls", other.Code, calls)
+	}
+
+	if reused := send("key-1", `{"code": "B"}`); reused.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 for key reuse with a different body, got %d", reused.Code)
+	}
+
+	send("", `{"code": "A"}`)
+	send("", `{"code": "A"}`)
+	if calls != 4 {
+		t.Fatalf("Expected requests without a key to always run, got %d calls", calls)
+	}
+
+	status = http.StatusServiceUnavailable
+	send("key-2", `{"code": "C"}`)
+	status = http.StatusCreated
+	if retried := send("key-2", `{"code": "C"}`); retried.Code != http.StatusCreated || calls != 6 {
+		t.Fatalf("Expected a failed request to be retryable, got status %d after %d calls", retried.Code, calls)
+	}
+}
+
+func TestIdempotentMiddlewareInProgress(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	store.records["POST /plans key-1"] = IdempotencyRecord{
+		Key:         "POST /plans key-1",
+		RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/plans", nil), []byte(`{}`)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	s := &Server{idempotencyStore: store, idempotencyTTL: time.Hour}
+	handler := s.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Handler must not run while the key is in progress")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 while in progress, got %d", recorder.Code)
+	}
+}
+
+func TestIdempotentMiddlewareBodyTooLarge(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	s := &Server{idempotencyStore: store, idempotencyTTL: time.Hour}
+	handler := s.idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("Handler must not run for an oversized body")
+	}))
+	body := `{"name": "` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(body))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected 413 for an oversized body, got %d", recorder.Code)
+	}
+	if len(store.records) != 0 {
+		t.Errorf("Expected no key to be reserved, got %v", store.records)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bss/src/database/memory"
+	"bss/src/metrics"
+	"bss/src/service"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	db := memory.New()
+	m := metrics.New()
+	m.RegisterActiveSubscriptions(db, time.Second)
+	s := NewServer(service.NewPlanService(db), service.NewSubscriptionService(db, service.WithSubscriptionMetrics(m)), db, WithMetrics(m, true))
+	plan := createPlan(t, s, validPlanBody)
+	customerId := uuid.NewString()
+	recorder := do(t, s, http.MethodPost, subscribePath(customerId), fmt.Sprintf(`{"plan_id": %q, "auto_renew": false}`, plan.ID))
+	expectStatus(t, recorder, http.StatusCreated)
+	subscription := decode[Subscription](t, recorder)
+	expectStatus(t, do(t, s, http.MethodPost, subscribePath(uuid.NewString()), fmt.Sprintf(`{"plan_id": %q, "auto_renew": false}`, plan.ID)), http.StatusCreated)
+	expectStatus(t, do(t, s, http.MethodPost, "/customers/"+customerId+"/unsubscribe?subscription_id="+subscription.ID.String(), ""), http.StatusNoContent)
+	expectStatus(t, do(t, s, http.MethodGet, "/plans/"+uuid.NewString(), ""), http.StatusNotFound)
+
+	recorder = do(t, s, http.MethodGet, "/metrics", "")
+	expectStatus(t, recorder, http.StatusOK)
+	body := recorder.Body.String()
+	for _, want := range []string{
+		fmt.Sprintf(`subscriptions_created_total{plan_id=%q} 2`, plan.ID),
+		fmt.Sprintf(`subscriptions_cancelled_total{plan_id=%q} 1`, plan.ID),
+		fmt.Sprintf(`subscriptions_active{plan_code="BASIC-30",plan_id=%q} 1`, plan.ID),
+		`http_requests_total{method="POST",route="/plans",status="201"} 1`,
+		`http_requests_total{method="GET",route="/plans/{id}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="POST",route="/customers/{customer_id}/subscribe",status="201"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in metrics:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsEndpointOnSeparatePort(t *testing.T) {
+	s := newServer(memory.New(), WithMetrics(metrics.New(), false))
+	expectStatus(t, do(t, s, http.MethodGet, "/metrics", ""), http.StatusNotFound)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize    = 10
+	defaultMaxPageSize = 100
+)
+
+// parsePageableRequest reads page, pageSize and cursor from the query.
+// Malformed or non-positive values are rejected and pageSize is clamped to
+// the server's maximum.
+func (s *Server) parsePageableRequest(r *http.Request) (PageableRequest, error) {
+	query := r.URL.Query()
+	pageableRequest := PageableRequest{
+		Page:     1,
+		PageSize: defaultPageSize,
+		Cursor:   query.Get("cursor"),
+	}
+	if pageStr := query.Get("page"); pageStr != "" {
+		if pageableRequest.Cursor != "" {
+			return PageableRequest{}, apperrors.Validation("page cannot be combined with cursor")
+		}
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return PageableRequest{}, apperrors.Validation("page must be a positive integer")
+		}
+		pageableRequest.Page = page
+	}
+	if pageSizeStr := query.Get("pageSize"); pageSizeStr != "" {
+		pageSize, err := strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 {
+			return PageableRequest{}, apperrors.Validation("pageSize must be a positive integer")
+		}
+		pageableRequest.PageSize = min(pageSize, s.maxPageSize)
+	}
+	return pageableRequest, nil
+}
+
+// writePage echoes the pagination parameters in page, sets RFC 8288 Link
+// headers for the next and previous pages and writes it as JSON.
+func writePage[V any](w http.ResponseWriter, r *http.Request, page Page[V], pageableRequest PageableRequest) {
+	page.PageSize = pageableRequest.PageSize
+	var links []string
+	if pageableRequest.Cursor == "" {
+		page.Page = pageableRequest.Page
+		if page.TotalCount != nil {
+			totalPages := (*page.TotalCount + int64(page.PageSize) - 1) / int64(page.PageSize)
+			page.TotalPages = &totalPages
+			if int64(page.Page) < totalPages {
+				links = append(links, pageLink(r, "next", "page", strconv.Itoa(page.Page+1)))
+			}
+			if page.Page > 1 {
+				prev := min(int64(page.Page-1), max(totalPages, 1))
+				links = append(links, pageLink(r, "prev", "page", strconv.FormatInt(prev, 10)))
+			}
+		}
+	} else if page.NextCursor != "" {
+		links = append(links, pageLink(r, "next", "cursor", page.NextCursor))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
+
+// pageLink is the request URL with key set to value, as a Link header entry.
+func pageLink(r *http.Request, rel string, key string, value string) string {
+	query := r.URL.Query()
+	query.Set(key, value)
+	link := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return "<" + link.String() + `>; rel="` + rel + `"`
+}
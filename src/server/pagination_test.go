@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"bss/src/database/memory"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePageableRequest(t *testing.T) {
+	s := &Server{maxPageSize: 50}
+	testCases := []struct {
+		name    string
+		query   string
+		want    PageableRequest
+		wantErr bool
+	}{
+		{"Defaults", "", PageableRequest{Page: 1, PageSize: 10}, false},
+		{"Explicit", "?page=3&pageSize=25", PageableRequest{Page: 3, PageSize: 25}, false},
+		{"ClampsPageSize", "?pageSize=10000000", PageableRequest{Page: 1, PageSize: 50}, false},
+		{"Cursor", "?cursor=abc&pageSize=5", PageableRequest{Page: 1, PageSize: 5, Cursor: "abc"}, false},
+		{"PageNotANumber", "?page=abc", PageableRequest{}, true},
+		{"PageZero", "?page=0", PageableRequest{}, true},
+		{"NegativePage", "?page=-1", PageableRequest{}, true},
+		{"PageSizeNotANumber", "?pageSize=ten", PageableRequest{}, true},
+		{"PageSizeZero", "?pageSize=0", PageableRequest{}, true},
+		{"PageSizeOverflow", "?pageSize=99999999999999999999", PageableRequest{}, true},
+		{"PageWithCursor", "?page=2&cursor=abc", PageableRequest{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.parsePageableRequest(httptest.NewRequest(http.MethodGet, "/plans"+tc.query, nil))
+			if tc.wantErr {
+				if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+					t.Errorf("Expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPaginationEchoAndLinks(t *testing.T) {
+	s := newServer(memory.New(), WithMaxPageSize(4))
+	for i := 0; i < 7; i++ {
+		createPlan(t, s, fmt.Sprintf(`{"code": "PLAN-%02d", "name": "Plan %d", "duration_days": 30}`, i, i))
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		wantPage       int
+		wantPageSize   int
+		wantTotalPages int64
+		wantLink       string
+	}{
+		{"FirstPage", "?pageSize=3&sort=code", 1, 3, 3, `</plans?page=2&pageSize=3&sort=code>; rel="next"`},
+		{"MiddlePage", "?page=2&pageSize=3", 2, 3, 3, `</plans?page=3&pageSize=3>; rel="next", </plans?page=1&pageSize=3>; rel="prev"`},
+		{"LastPage", "?page=3&pageSize=3", 3, 3, 3, `</plans?page=2&pageSize=3>; rel="prev"`},
+		{"PastTheEnd", "?page=9&pageSize=3", 9, 3, 3, `</plans?page=3&pageSize=3>; rel="prev"`},
+		{"ClampedPageSize", "?pageSize=1000", 1, 4, 2, `</plans?page=2&pageSize=1000>; rel="next"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/plans"+tc.query, "")
+			expectStatus(t, recorder, http.StatusOK)
+			page := decode[Page[Plan]](t, recorder)
+			if page.Page != tc.wantPage || page.PageSize != tc.wantPageSize || page.TotalPages == nil || *page.TotalPages != tc.wantTotalPages {
+				t.Errorf("Expected page %d, page_size %d, total_pages %d, got %d, %d, %v", tc.wantPage, tc.wantPageSize, tc.wantTotalPages, page.Page, page.PageSize, page.TotalPages)
+			}
+			if link := recorder.Header().Get("Link"); link != tc.wantLink {
+				t.Errorf("Expected Link %q, got %q", tc.wantLink, link)
+			}
+		})
+	}
+
+	recorder := do(t, s, http.MethodGet, "/plans?pageSize=4", "")
+	first := decode[Page[Plan]](t, recorder)
+	recorder = do(t, s, http.MethodGet, "/plans?pageSize=4&cursor="+first.NextCursor, "")
+	expectStatus(t, recorder, http.StatusOK)
+	page := decode[Page[Plan]](t, recorder)
+	if page.Page != 0 || page.TotalPages != nil || page.PageSize != 4 || len(page.Items) != 3 {
+		t.Errorf("Expected a final cursor page of 3 with no page numbers, got %+v", page)
+	}
+	if link := recorder.Header().Get("Link"); link != "" {
+		t.Errorf("Expected no Link header on the last cursor page, got %q", link)
+	}
+
+	first = decode[Page[Plan]](t, do(t, s, http.MethodGet, "/plans?pageSize=2", ""))
+	recorder = do(t, s, http.MethodGet, "/plans?pageSize=2&cursor="+first.NextCursor, "")
+	second := decode[Page[Plan]](t, recorder)
+	if want := `</plans?cursor=` + second.NextCursor + `&pageSize=2>; rel="next"`; recorder.Header().Get("Link") != want {
+		t.Errorf("Expected Link %q, got %q", want, recorder.Header().Get("Link"))
+	}
+}
@@ -1,101 +1,244 @@
 package server
 
 import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"bss/src/service"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 func (s *Server) setupPlanRoutes() {
-	s.router.Post("/plans", s.handleCreatePlan)
+	s.router.With(s.idempotent).Post("/plans", s.handleCreatePlan)
 	s.router.Get("/plans", s.handleGetPlans)
 	s.router.Get("/plans/{id}", s.handleGetPlan)
+	s.router.Get("/plans/by-code/{code}", s.handleGetPlanByCode)
 	s.router.Put("/plans/{id}", s.handleUpdatePlan)
+	s.router.Patch("/plans/{id}", s.handlePatchPlan)
+	s.router.Get("/plans/{id}/versions", s.handleGetPlanVersions)
+	s.router.Post("/plans/{id}/retire", s.handleRetirePlan)
 }
 
 func (s *Server) handleCreatePlan(w http.ResponseWriter, r *http.Request) {
 	var plan Plan
-	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(w, r, &plan, func() error { return s.plans.ValidatePlan(plan) }); err != nil {
+		writeError(w, err)
 		return
 	}
-	defer r.Body.Close()
-	createdPlan, err := s.db.CreatePlan(r.Context(), plan)
+	createdPlan, err := s.plans.CreatePlan(r.Context(), plan)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdPlan)
+	writePlan(w, http.StatusCreated, createdPlan)
 }
 
 func (s *Server) handleGetPlans(w http.ResponseWriter, r *http.Request) {
-	page := 1
-	pageSize := 10
-
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
+	pageableRequest, err := s.parsePageableRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter, err := parsePlanFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	plansPage, err := s.plans.GetPlans(r.Context(), filter, pageableRequest)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
+	writePage(w, r, plansPage, pageableRequest)
+}
 
-	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
-		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 {
-			pageSize = ps
-		}
+// parsePlanFilter reads the GET /plans filters. Only active plans are listed
+// unless active=false or active=all is given.
+func parsePlanFilter(query url.Values) (PlanFilter, error) {
+	active := true
+	filter := PlanFilter{
+		Active:     &active,
+		Currency:   strings.ToUpper(query.Get("currency")),
+		CodePrefix: query.Get("code_prefix"),
+		Name:       query.Get("name"),
+		Sort:       models.ParseSort(query.Get("sort")),
+	}
+	switch activeStr := query.Get("active"); activeStr {
+	case "", "true":
+	case "false":
+		active = false
+	case "all":
+		filter.Active = nil
+	default:
+		return PlanFilter{}, apperrors.Validation("active must be true, false or all")
+	}
+	var err error
+	if filter.MinPriceCents, err = parseOptionalInt[int64](query, "min_price_cents"); err != nil {
+		return PlanFilter{}, err
+	}
+	if filter.MaxPriceCents, err = parseOptionalInt[int64](query, "max_price_cents"); err != nil {
+		return PlanFilter{}, err
+	}
+	if filter.MinDurationDays, err = parseOptionalInt[int](query, "min_duration_days"); err != nil {
+		return PlanFilter{}, err
 	}
+	if filter.MaxDurationDays, err = parseOptionalInt[int](query, "max_duration_days"); err != nil {
+		return PlanFilter{}, err
+	}
+	return filter, nil
+}
 
-	pageableRequest := PageableRequest{
-		Page:     page,
-		PageSize: pageSize,
+// parseOptionalInt parses the named query parameter, returning nil when it
+// is absent.
+func parseOptionalInt[T int | int64](query url.Values, name string) (*T, error) {
+	valueStr := query.Get(name)
+	if valueStr == "" {
+		return nil, nil
 	}
-	plansPage, err := s.db.GetPlans(r.Context(), pageableRequest)
+	value, err := strconv.ParseInt(valueStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, apperrors.Validation("invalid %s", name)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(plansPage)
+	v := T(value)
+	return &v, nil
 }
 
 func (s *Server) handleGetPlan(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
+	planId, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid plan id"))
+		return
+	}
 
-	plan, err := s.db.GetPlan(r.Context(), idStr)
+	plan, err := s.plans.GetPlan(r.Context(), planId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	writePlan(w, http.StatusOK, plan)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(plan)
+func (s *Server) handleGetPlanByCode(w http.ResponseWriter, r *http.Request) {
+	plan, err := s.plans.GetPlanByCode(r.Context(), r.PathValue("code"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writePlan(w, http.StatusOK, plan)
 }
 
 func (s *Server) handleUpdatePlan(w http.ResponseWriter, r *http.Request) {
-	idStr := r.PathValue("id")
-	planId, err := uuid.Parse(idStr)
+	planId, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "invalid plan id", http.StatusBadRequest)
+		writeError(w, apperrors.Validation("invalid plan id"))
 		return
 	}
-	var plan Plan
-	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req service.UpdatePlanRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
-	defer r.Body.Close()
-	plan.ID = planId
-	updatedPlan, err := s.db.UpdatePlan(r.Context(), plan)
+	updatedPlan, err := s.plans.UpdatePlan(r.Context(), planId, req, ifMatchVersion(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	writePlan(w, http.StatusOK, updatedPlan)
+}
+
+func (s *Server) handlePatchPlan(w http.ResponseWriter, r *http.Request) {
+	planId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid plan id"))
+		return
+	}
+	patch, err := readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	patchedPlan, err := s.plans.PatchPlan(r.Context(), planId, patch, ifMatchVersion(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writePlan(w, http.StatusOK, patchedPlan)
+}
+
+// planETag is the entity tag of a plan, derived from its version.
+func planETag(plan Plan) string {
+	return `"` + strconv.Itoa(plan.Version) + `"`
+}
+
+// writePlan writes plan as JSON along with its ETag.
+func writePlan(w http.ResponseWriter, status int, plan Plan) {
+	w.Header().Set("ETag", planETag(plan))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(plan)
+}
+
+// ifMatchVersion returns the plan version required by the If-Match header,
+// or 0 when there is none or it is "*". Only a single strong entity tag is
+// understood; anything else yields -1, which never matches, so the update
+// fails with 412 instead of being applied unconditionally.
+func ifMatchVersion(r *http.Request) int {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return 0
+	}
+	tag, ok := strings.CutPrefix(ifMatch, `"`)
+	if !ok {
+		return -1
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok {
+		return -1
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return -1
+	}
+	return version
+}
+
+func (s *Server) handleGetPlanVersions(w http.ResponseWriter, r *http.Request) {
+	planId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid plan id"))
+		return
+	}
+	versions, err := s.plans.GetPlanVersions(r.Context(), planId)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updatedPlan)
+	json.NewEncoder(w).Encode(Page[PlanVersion]{Items: versions})
+}
+
+func (s *Server) handleRetirePlan(w http.ResponseWriter, r *http.Request) {
+	planId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid plan id"))
+		return
+	}
+	var req service.RetirePlanRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	retiredPlan, err := s.plans.RetirePlan(r.Context(), planId, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writePlan(w, http.StatusOK, retiredPlan)
 }
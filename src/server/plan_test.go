@@ -0,0 +1,452 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreatePlanHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"Valid", validPlanBody, http.StatusCreated, ""},
+		{"MalformedJSON", `{"code": "BASIC-30",`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"WrongType", `{"code": "BASIC-30", "price_cents": "free"}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MissingCode", `{"name": "Basic", "duration_days": 30}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"NegativePrice", `{"code": "BASIC-30", "name": "Basic", "price_cents": -1, "duration_days": 30}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"ZeroDuration", `{"code": "BASIC-30", "name": "Basic", "duration_days": 0}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadCurrency", `{"code": "BASIC-30", "name": "Basic", "currency": "dollars", "duration_days": 30}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadCode", `{"code": "basic 30", "name": "Basic", "duration_days": 30}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"UnknownField", `{"code": "BASIC-30", "name": "Basic", "duration_days": 30, "colour": "red"}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+			recorder := do(t, s, http.MethodPost, "/plans", tc.body)
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			plan := decode[Plan](t, recorder)
+			if plan.ID == uuid.Nil || plan.Code != "BASIC-30" || !plan.Active || plan.CreatedAt.IsZero() {
+				t.Errorf("Unexpected plan %+v", plan)
+			}
+		})
+	}
+}
+
+func TestCreatePlanHandlerReportsEveryField(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{
+			"Validation",
+			`{"code": "", "name": "Basic", "price_cents": -1, "currency": "dollars", "duration_days": 0}`,
+			[]string{"code", "price_cents", "currency", "duration_days"},
+		},
+		{
+			"DecodeAndValidation",
+			`{"foo": 1, "price_cents": -5, "currency": "dollars"}`,
+			[]string{"foo", "code", "name", "price_cents", "currency", "duration_days"},
+		},
+		{
+			"WrongTypeNotReportedTwice",
+			`{"code": "BASIC-30", "name": "Basic", "duration_days": "thirty", "data_mb": "lots", "currency": "dollars"}`,
+			[]string{"duration_days", "data_mb", "currency"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+			recorder := do(t, s, http.MethodPost, "/plans", tc.body)
+			expectStatus(t, recorder, http.StatusBadRequest)
+			body := decode[errorResponse](t, recorder)
+			var fields []string
+			for _, field := range body.Error.Fields {
+				fields = append(fields, field.Field)
+			}
+			if !slices.Equal(fields, tc.wantFields) {
+				t.Errorf("Expected invalid fields %v, got %v", tc.wantFields, fields)
+			}
+		})
+	}
+}
+
+func TestCreatePlanHandlerDuplicateCode(t *testing.T) {
+	s, _ := newTestServer(t)
+	createPlan(t, s, validPlanBody)
+	recorder := do(t, s, http.MethodPost, "/plans", validPlanBody)
+	expectStatus(t, recorder, http.StatusConflict)
+	expectErrorCode(t, recorder, apperrors.CodePlanAlreadyExists)
+}
+
+func TestCreatePlanHandlerDefaultsCurrency(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, `{"code": "BASIC-30", "name": "Basic", "duration_days": 30}`)
+	if plan.Currency != "USD" {
+		t.Errorf("Expected currency to default to USD, got %q", plan.Currency)
+	}
+}
+
+func TestGetPlansHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	for i := 0; i < 12; i++ {
+		createPlan(t, s, fmt.Sprintf(`{"code": "PLAN-%02d", "name": "Plan %d", "duration_days": 30}`, i, i))
+	}
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantItems  int
+	}{
+		{"Defaults", "", http.StatusOK, 10},
+		{"SecondPage", "?page=2", http.StatusOK, 2},
+		{"PageSize", "?pageSize=5", http.StatusOK, 5},
+		{"PageAndPageSize", "?page=3&pageSize=5", http.StatusOK, 2},
+		{"PastTheEnd", "?page=4&pageSize=5", http.StatusOK, 0},
+		{"InvalidPage", "?page=abc", http.StatusBadRequest, 0},
+		{"NegativePageSize", "?pageSize=-3", http.StatusBadRequest, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/plans"+tc.query, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantStatus != http.StatusOK {
+				expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+				return
+			}
+			page := decode[Page[Plan]](t, recorder)
+			expectTotal(t, page, 12)
+			if len(page.Items) != tc.wantItems {
+				t.Errorf("Expected %d items, got %d", tc.wantItems, len(page.Items))
+			}
+		})
+	}
+}
+
+func TestGetPlanHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+
+	testCases := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"Found", plan.ID.String(), http.StatusOK, ""},
+		{"BadUUID", "not-a-uuid", http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"Unknown", uuid.NewString(), http.StatusNotFound, apperrors.CodePlanNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/plans/"+tc.id, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			if got := decode[Plan](t, recorder); got.ID != plan.ID || got.Code != plan.Code {
+				t.Errorf("Expected %+v, got %+v", plan, got)
+			}
+		})
+	}
+}
+
+func TestUpdatePlanHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	other := createPlan(t, s, `{"code": "OTHER-30", "name": "Other", "duration_days": 30}`)
+
+	testCases := []struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"Valid", plan.ID.String(), `{"code": "BASIC-30", "name": "Basic Plus", "price_cents": 1299, "currency": "USD", "duration_days": 30, "active": true}`, http.StatusOK, ""},
+		{"BadUUID", "not-a-uuid", validPlanBody, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MalformedJSON", plan.ID.String(), `{"name": }`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"Invalid", plan.ID.String(), `{"code": "", "name": "Basic", "duration_days": 30}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"Unknown", uuid.NewString(), validPlanBody, http.StatusNotFound, apperrors.CodePlanNotFound},
+		{"DuplicateCode", other.ID.String(), validPlanBody, http.StatusConflict, apperrors.CodePlanAlreadyExists},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodPut, "/plans/"+tc.id, tc.body)
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			updated := decode[Plan](t, recorder)
+			if updated.ID != plan.ID || updated.Name != "Basic Plus" || updated.PriceCents != 1299 {
+				t.Errorf("Unexpected plan %+v", updated)
+			}
+		})
+	}
+}
+
+func TestGetPlansHandlerFilters(t *testing.T) {
+	s, _ := newTestServer(t)
+	createPlan(t, s, `{"code": "BASIC-30", "name": "Basic", "price_cents": 500, "currency": "USD", "duration_days": 30}`)
+	createPlan(t, s, `{"code": "PREMIUM-30", "name": "Premium", "price_cents": 2000, "currency": "USD", "duration_days": 30}`)
+	createPlan(t, s, `{"code": "PREMIUM-365", "name": "Premium Yearly", "price_cents": 20000, "currency": "EUR", "duration_days": 365}`)
+	legacy := createPlan(t, s, `{"code": "LEGACY-30", "name": "Legacy", "price_cents": 100, "currency": "USD", "duration_days": 30}`)
+	expectStatus(t, do(t, s, http.MethodPut, "/plans/"+legacy.ID.String(), `{"code": "LEGACY-30", "name": "Legacy", "price_cents": 100, "currency": "USD", "duration_days": 30, "active": false}`), http.StatusOK)
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCodes  []string
+	}{
+		{"ActiveByDefault", "?sort=code", http.StatusOK, []string{"BASIC-30", "PREMIUM-30", "PREMIUM-365"}},
+		{"Inactive", "?active=false", http.StatusOK, []string{"LEGACY-30"}},
+		{"All", "?active=all&sort=-price_cents", http.StatusOK, []string{"PREMIUM-365", "PREMIUM-30", "BASIC-30", "LEGACY-30"}},
+		{"CurrencyIsCaseInsensitive", "?currency=eur", http.StatusOK, []string{"PREMIUM-365"}},
+		{"PriceRange", "?min_price_cents=500&max_price_cents=2000&sort=price_cents", http.StatusOK, []string{"BASIC-30", "PREMIUM-30"}},
+		{"DurationRange", "?min_duration_days=31", http.StatusOK, []string{"PREMIUM-365"}},
+		{"CodePrefix", "?code_prefix=PREMIUM&sort=code", http.StatusOK, []string{"PREMIUM-30", "PREMIUM-365"}},
+		{"NameSearch", "?name=yearly", http.StatusOK, []string{"PREMIUM-365"}},
+		{"InvalidActive", "?active=yes", http.StatusBadRequest, nil},
+		{"InvalidPrice", "?min_price_cents=cheap", http.StatusBadRequest, nil},
+		{"InvertedPriceRange", "?min_price_cents=10&max_price_cents=5", http.StatusBadRequest, nil},
+		{"InvertedDurationRange", "?min_duration_days=30&max_duration_days=7", http.StatusBadRequest, nil},
+		{"UnknownSortField", "?sort=price_cents,secret", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/plans"+tc.query, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantStatus != http.StatusOK {
+				expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+				return
+			}
+			page := decode[Page[Plan]](t, recorder)
+			var codes []string
+			for _, plan := range page.Items {
+				codes = append(codes, plan.Code)
+			}
+			if fmt.Sprint(codes) != fmt.Sprint(tc.wantCodes) {
+				t.Errorf("Expected %v, got %v", tc.wantCodes, codes)
+			}
+			expectTotal(t, page, int64(len(tc.wantCodes)))
+		})
+	}
+}
+
+func TestGetPlanVersionsHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	expectStatus(t, do(t, s, http.MethodPut, "/plans/"+plan.ID.String(), `{"code": "BASIC-30", "name": "Basic", "price_cents": 1299, "currency": "USD", "duration_days": 30, "data_mb": 5120, "active": true}`), http.StatusOK)
+	subscription := decode[Subscription](t, do(t, s, http.MethodPost, "/customers/"+uuid.NewString()+"/subscribe", fmt.Sprintf(`{"plan_id": %q}`, plan.ID)))
+	if subscription.PlanVersion != 2 {
+		t.Errorf("Expected new subscriptions to be on version 2, got %d", subscription.PlanVersion)
+	}
+
+	recorder := do(t, s, http.MethodGet, "/plans/"+plan.ID.String()+"/versions", "")
+	expectStatus(t, recorder, http.StatusOK)
+	page := decode[Page[PlanVersion]](t, recorder)
+	if len(page.Items) != 2 {
+		t.Fatalf("Expected 2 versions, got %+v", page.Items)
+	}
+	if page.Items[0].Version != 2 || page.Items[0].PriceCents != 1299 || page.Items[1].Version != 1 || page.Items[1].PriceCents != 999 {
+		t.Errorf("Expected versions 2 (1299) and 1 (999), got %+v", page.Items)
+	}
+
+	recorder = do(t, s, http.MethodGet, "/plans/not-a-uuid/versions", "")
+	expectStatus(t, recorder, http.StatusBadRequest)
+	recorder = do(t, s, http.MethodGet, "/plans/"+uuid.NewString()+"/versions", "")
+	expectStatus(t, recorder, http.StatusNotFound)
+	expectErrorCode(t, recorder, apperrors.CodePlanNotFound)
+}
+
+func TestPatchPlanHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	path := "/plans/" + plan.ID.String()
+
+	testCases := []struct {
+		name        string
+		path        string
+		ifMatch     string
+		body        string
+		wantStatus  int
+		wantCode    apperrors.Code
+		wantVersion int
+	}{
+		{"ChangePrice", path, "", `{"price_cents": 1299}`, http.StatusOK, "", 2},
+		{"MatchingIfMatch", path, `"2"`, `{"name": "Basic Plus"}`, http.StatusOK, "", 3},
+		{"StaleIfMatch", path, `"2"`, `{"name": "Lost Update"}`, http.StatusPreconditionFailed, apperrors.CodePlanModified, 0},
+		{"WeakIfMatch", path, `W/"3"`, `{"name": "Weak"}`, http.StatusPreconditionFailed, apperrors.CodePlanModified, 0},
+		{"AnyIfMatch", path, `*`, `{"data_mb": 10240}`, http.StatusOK, "", 4},
+		{"NoChange", path, `"4"`, `{"data_mb": 10240}`, http.StatusOK, "", 4},
+		{"Empty", path, "", `{}`, http.StatusOK, "", 4},
+		{"TooLarge", path, "", `{"name": "` + strings.Repeat("a", maxRequestBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, apperrors.CodeRequestTooLarge, 0},
+		{"Invalid", path, "", `{"duration_days": 0}`, http.StatusBadRequest, apperrors.CodeValidationFailed, 0},
+		{"ReadOnly", path, "", `{"updated_at": "2020-01-01T00:00:00Z"}`, http.StatusBadRequest, apperrors.CodeValidationFailed, 0},
+		{"MalformedJSON", path, "", `{"name": `, http.StatusBadRequest, apperrors.CodeValidationFailed, 0},
+		{"BadUUID", "/plans/not-a-uuid", "", `{}`, http.StatusBadRequest, apperrors.CodeValidationFailed, 0},
+		{"Unknown", "/plans/" + uuid.NewString(), "", `{}`, http.StatusNotFound, apperrors.CodePlanNotFound, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			recorder := httptest.NewRecorder()
+			s.router.ServeHTTP(recorder, req)
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			patched := decode[Plan](t, recorder)
+			if patched.Version != tc.wantVersion {
+				t.Errorf("Expected version %d, got %d", tc.wantVersion, patched.Version)
+			}
+			if etag := recorder.Header().Get("ETag"); etag != fmt.Sprintf(`"%d"`, tc.wantVersion) {
+				t.Errorf("Expected ETag for version %d, got %q", tc.wantVersion, etag)
+			}
+		})
+	}
+
+	final := decode[Plan](t, do(t, s, http.MethodGet, path, ""))
+	if final.PriceCents != 1299 || final.Name != "Basic Plus" || final.DataMB != 10240 || !final.Active || final.Code != plan.Code {
+		t.Errorf("Expected only the patched fields to change, got %+v", final)
+	}
+}
+
+func TestUpdatePlanHandlerKeepsActiveAndTimestamps(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	path := "/plans/" + plan.ID.String()
+
+	recorder := do(t, s, http.MethodGet, path, "")
+	expectStatus(t, recorder, http.StatusOK)
+	if etag := recorder.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("Expected ETag \"1\", got %q", etag)
+	}
+
+	body := `{"code": "BASIC-30", "name": "Basic", "price_cents": 1299, "currency": "USD", "duration_days": 30, "data_mb": 5120, "updated_at": "2001-01-01T00:00:00Z", "created_at": "2001-01-01T00:00:00Z"}`
+	recorder = do(t, s, http.MethodPut, path, body)
+	expectStatus(t, recorder, http.StatusOK)
+	updated := decode[Plan](t, recorder)
+	if !updated.Active {
+		t.Errorf("Expected an omitted active to keep the plan active")
+	}
+	if updated.UpdatedAt.Year() == 2001 || !updated.CreatedAt.Equal(plan.CreatedAt) {
+		t.Errorf("Expected server-managed timestamps, got created %v updated %v", updated.CreatedAt, updated.UpdatedAt)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+	req.Header.Set("If-Match", `"1"`)
+	recorder = httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	expectStatus(t, recorder, http.StatusPreconditionFailed)
+	expectErrorCode(t, recorder, apperrors.CodePlanModified)
+}
+
+func TestRetirePlanHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	successor := createPlan(t, s, `{"code": "BASIC-30-V2", "name": "Basic", "price_cents": 1099, "duration_days": 30}`)
+	path := "/plans/" + plan.ID.String() + "/retire"
+
+	testCases := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"BadUUID", "/plans/not-a-uuid/retire", `{}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MalformedJSON", path, `{"effective_date": `, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadEffectiveDate", path, `{"effective_date": "tomorrow"}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadSuccessorUUID", path, `{"successor_plan_id": "not-a-uuid"}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"UnknownSuccessor", path, fmt.Sprintf(`{"successor_plan_id": %q}`, uuid.New()), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"UnknownPlan", "/plans/" + uuid.NewString() + "/retire", `{}`, http.StatusNotFound, apperrors.CodePlanNotFound},
+		{"Valid", path, fmt.Sprintf(`{"effective_date": "2025-01-15", "successor_plan_id": %q}`, successor.ID), http.StatusOK, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodPost, tc.path, tc.body)
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			retired := decode[Plan](t, recorder)
+			if retired.RetiredAt == nil || retired.RetiredAt.Format("2006-01-02") != "2025-01-15" {
+				t.Errorf("Expected retirement on 2025-01-15, got %v", retired.RetiredAt)
+			}
+			if retired.SuccessorPlanID == nil || *retired.SuccessorPlanID != successor.ID {
+				t.Errorf("Expected successor %s, got %v", successor.ID, retired.SuccessorPlanID)
+			}
+		})
+	}
+
+	recorder := do(t, s, http.MethodPost, subscribePath(uuid.NewString()), fmt.Sprintf(`{"plan_id": %q}`, plan.ID))
+	expectStatus(t, recorder, http.StatusBadRequest)
+	expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+	recorder = do(t, s, http.MethodPatch, "/plans/"+plan.ID.String(), `{"retired_at": null}`)
+	expectStatus(t, recorder, http.StatusBadRequest)
+}
+
+func TestGetPlanByCodeHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+
+	testCases := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{"Found", "BASIC-30", http.StatusOK},
+		{"CaseSensitive", "basic-30", http.StatusNotFound},
+		{"Unknown", "PREMIUM-30", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/plans/by-code/"+tc.code, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantStatus != http.StatusOK {
+				expectErrorCode(t, recorder, apperrors.CodePlanNotFound)
+				return
+			}
+			if got := decode[Plan](t, recorder); got.ID != plan.ID {
+				t.Errorf("Expected plan %s, got %s", plan.ID, got.ID)
+			}
+			if etag := recorder.Header().Get("ETag"); etag != `"1"` {
+				t.Errorf("Expected ETag \"1\", got %q", etag)
+			}
+		})
+	}
+}
@@ -2,51 +2,146 @@ package server
 
 import (
 	"bss/src/database"
+	"bss/src/health"
+	"bss/src/metrics"
+	"bss/src/service"
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PageableRequest = database.PageableRequest
 type Page[V any] = database.Page[V]
 type Plan = database.Plan
+type PlanVersion = database.PlanVersion
+type PlanFilter = database.PlanFilter
 type Subscription = database.Subscription
 type Event = database.Event
+type EventFilter = database.EventFilter
+type IdempotencyRecord = database.IdempotencyRecord
 
-type Database interface {
-	CreatePlan(ctx context.Context, plan Plan) (Plan, error)
-	GetPlans(ctx context.Context, pageableRequest PageableRequest) (Page[Plan], error)
-	GetPlan(ctx context.Context, id string) (Plan, error)
-	UpdatePlan(ctx context.Context, plan Plan) (Plan, error)
+// EventReader replays the event log behind GET /events.
+type EventReader interface {
+	StreamEvents(ctx context.Context, filter EventFilter, fn func(Event) error) error
+}
 
-	CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
-	GetSubscriptionsByUserId(ctx context.Context, pageableRequest PageableRequest, userId string) (Page[Subscription], error)
-	CancelSubscription(ctx context.Context, id string, custId string) error
+// Database is everything the server's dependencies need from persistence:
+// the services' Database plus read access to the event log.
+type Database interface {
+	service.Database
+	EventReader
 }
 
 type Server struct {
-	router *chi.Mux
-	db     Database
+	router        *chi.Mux
+	events        EventReader
+	plans         *service.PlanService
+	subscriptions *service.SubscriptionService
+	maxPageSize   int
+
+	idempotencyStore IdempotencyStore
+	idempotencyTTL   time.Duration
+
+	health *health.Registry
+
+	metrics      *metrics.Metrics
+	serveMetrics bool
+
+	tracerProvider trace.TracerProvider
+}
+
+// Option configures optional Server features.
+type Option func(*Server)
+
+// WithIdempotency enables Idempotency-Key handling on POST endpoints, keeping
+// stored responses for ttl.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) Option {
+	return func(s *Server) {
+		s.idempotencyStore = store
+		s.idempotencyTTL = ttl
+	}
+}
+
+// WithHealth serves /healthz and /readyz from registry's checks instead of
+// an empty registry.
+func WithHealth(registry *health.Registry) Option {
+	return func(s *Server) {
+		s.health = registry
+	}
 }
 
-func NewServer(db Database) *Server {
+// WithMetrics records HTTP metrics to m, and serves the registry on /metrics
+// when serve is set. Pass serve=false when the metrics have a port of their
+// own.
+func WithMetrics(m *metrics.Metrics, serve bool) Option {
+	return func(s *Server) {
+		s.metrics = m
+		s.serveMetrics = serve
+	}
+}
+
+// WithTracerProvider records request spans to provider instead of the
+// global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *Server) {
+		s.tracerProvider = provider
+	}
+}
+
+// WithMaxPageSize caps the pageSize list endpoints accept; larger requests
+// are clamped to n.
+func WithMaxPageSize(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxPageSize = n
+		}
+	}
+}
+
+// NewServer serves the API on top of services built by the caller, which
+// can share them with background jobs such as the renewal sweep.
+func NewServer(plans *service.PlanService, subscriptions *service.SubscriptionService, events EventReader, opts ...Option) *Server {
 	s := &Server{
-		router: chi.NewRouter(),
-		db:     db,
+		router:         chi.NewRouter(),
+		events:         events,
+		plans:          plans,
+		subscriptions:  subscriptions,
+		maxPageSize:    defaultMaxPageSize,
+		idempotencyTTL: 24 * time.Hour,
+		health:         health.NewRegistry(),
+		tracerProvider: otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.setupRoutes()
 	return s
 }
 
 func (s *Server) setupRoutes() {
+	s.router.Use(tracingMiddleware(s.tracerProvider))
 	s.router.Use(middleware.Logger)
+	if s.metrics != nil {
+		// Outside Recoverer, so that panics are counted as the 500s they
+		// turn into.
+		s.router.Use(s.metrics.Middleware)
+	}
 	s.router.Use(middleware.Recoverer)
 
+	if s.metrics != nil && s.serveMetrics {
+		s.router.Method(http.MethodGet, "/metrics", s.metrics.Handler())
+	}
+
 	s.router.Get("/hello", s.handleHello)
+	s.setupHealthRoutes()
 	s.setupPlanRoutes()
 	s.setupSubscriptionRoutes()
+	s.setupEventRoutes()
 }
 
 func (s *Server) handleHello(w http.ResponseWriter, r *http.Request) {
@@ -55,6 +150,8 @@ func (s *Server) handleHello(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"message": "Hello, World!"}`))
 }
 
-func (s *Server) Start(addr string) error {
-	return http.ListenAndServe(addr, s.router)
+// Handler returns the router, for the caller to serve with an http.Server
+// of its own.
+func (s *Server) Handler() http.Handler {
+	return s.router
 }
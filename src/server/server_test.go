@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"bss/src/database/memory"
+	"bss/src/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const validPlanBody = `{"code": "BASIC-30", "name": "Basic", "price_cents": 999, "currency": "USD", "duration_days": 30, "data_mb": 5120}`
+
+func newTestServer(t *testing.T) (*Server, *memory.DB) {
+	t.Helper()
+	db := memory.New()
+	return newServer(db), db
+}
+
+// newServer builds the server on default services over db.
+func newServer(db Database, opts ...Option) *Server {
+	return NewServer(service.NewPlanService(db), service.NewSubscriptionService(db), db, opts...)
+}
+
+func do(t *testing.T, s *Server, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func decode[T any](t *testing.T, recorder *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(recorder.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Failed to decode body %q: %v", recorder.Body.String(), err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, recorder *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, recorder.Code, recorder.Body.String())
+	}
+}
+
+func expectErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, code apperrors.Code) {
+	t.Helper()
+	if body := decode[errorResponse](t, recorder); body.Error.Code != code {
+		t.Errorf("Expected error code %s, got %s", code, body.Error.Code)
+	}
+}
+
+func expectTotal[V any](t *testing.T, page Page[V], want int64) {
+	t.Helper()
+	if page.TotalCount == nil || *page.TotalCount != want {
+		t.Errorf("Expected total count %d, got %v", want, page.TotalCount)
+	}
+}
+
+func createPlan(t *testing.T, s *Server, body string) Plan {
+	t.Helper()
+	recorder := do(t, s, http.MethodPost, "/plans", body)
+	expectStatus(t, recorder, http.StatusCreated)
+	return decode[Plan](t, recorder)
+}
+
+// unavailableDB behaves like a database whose connection is down.
+type unavailableDB struct {
+	*memory.DB
+}
+
+var errUnavailable = apperrors.Wrap(apperrors.CodeServiceUnavailable, "database unavailable", errors.New("dial tcp: connection refused"))
+
+func (unavailableDB) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return errUnavailable
+}
+
+func (unavailableDB) GetPlans(ctx context.Context, filter PlanFilter, pageableRequest PageableRequest) (Page[Plan], error) {
+	return Page[Plan]{}, errUnavailable
+}
+
+func (unavailableDB) GetPlan(ctx context.Context, id string) (Plan, error) {
+	return Plan{}, errUnavailable
+}
+
+func TestHello(t *testing.T) {
+	s, _ := newTestServer(t)
+	recorder := do(t, s, http.MethodGet, "/hello", "")
+	expectStatus(t, recorder, http.StatusOK)
+	if body := decode[map[string]string](t, recorder); body["message"] != "Hello, World!" {
+		t.Errorf("Unexpected body %v", body)
+	}
+}
+
+// TestScenarioMatrix covers the example test case matrix in HLD §8 that is
+// reachable over HTTP.
+func TestScenarioMatrix(t *testing.T) {
+	testCases := []struct {
+		name       string
+		db         func() Database
+		setup      func(t *testing.T, s *Server) (method, path, body string)
+		wantStatus int
+	}{
+		{
+			name: "CreatePlanWithValidPayload",
+			setup: func(t *testing.T, s *Server) (string, string, string) {
+				return http.MethodPost, "/plans", validPlanBody
+			},
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name: "SubscribeToPlanTwice",
+			setup: func(t *testing.T, s *Server) (string, string, string) {
+				plan := createPlan(t, s, validPlanBody)
+				body := `{"plan_id": "` + plan.ID.String() + `"}`
+				expectStatus(t, do(t, s, http.MethodPost, "/customers/7f3c1d2e-4b5a-4c6d-8e9f-0a1b2c3d4e5f/subscribe", body), http.StatusCreated)
+				return http.MethodPost, "/customers/7f3c1d2e-4b5a-4c6d-8e9f-0a1b2c3d4e5f/subscribe", body
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "InvalidPlanIdInSubscribe",
+			setup: func(t *testing.T, s *Server) (string, string, string) {
+				return http.MethodPost, "/customers/7f3c1d2e-4b5a-4c6d-8e9f-0a1b2c3d4e5f/subscribe", `{"plan_id": "00000000-0000-0000-0000-000000000001"}`
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "DatabaseDown",
+			db:   func() Database { return unavailableDB{memory.New()} },
+			setup: func(t *testing.T, s *Server) (string, string, string) {
+				return http.MethodGet, "/plans", ""
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var db Database = memory.New()
+			if tc.db != nil {
+				db = tc.db()
+			}
+			s := newServer(db)
+			method, path, body := tc.setup(t, s)
+			expectStatus(t, do(t, s, method, path, body), tc.wantStatus)
+		})
+	}
+}
@@ -1,15 +1,16 @@
 package server
 
 import (
+	"bss/src/apperrors"
+	"bss/src/service"
 	"encoding/json"
 	"net/http"
-	"strconv"
 
 	"github.com/google/uuid"
 )
 
 func (d *Server) setupSubscriptionRoutes() {
-	d.router.Post("/customers/{customer_id}/subscribe", d.handleCreateSubscription)
+	d.router.With(d.idempotent).Post("/customers/{customer_id}/subscribe", d.handleCreateSubscription)
 	d.router.Get("/customers/{customer_id}/subscriptions", d.handleGetSubscriptionsByUserId)
 	d.router.Post("/customers/{customer_id}/unsubscribe", d.handleCancelSubscription)
 }
@@ -18,24 +19,17 @@ func (d *Server) handleCreateSubscription(w http.ResponseWriter, r *http.Request
 	customerId := r.PathValue("customer_id")
 	customerUUID, err := uuid.Parse(customerId)
 	if err != nil {
-		http.Error(w, "invalid customer_id", http.StatusBadRequest)
+		writeError(w, apperrors.Validation("invalid customer_id"))
 		return
 	}
-	var subscription Subscription
-	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req service.SubscribeRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
-	defer r.Body.Close()
-	_, err = d.db.GetPlan(r.Context(), subscription.PlanID.String())
+	createdSubscription, err := d.subscriptions.Subscribe(r.Context(), customerUUID, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	subscription.CustomerID = customerUUID
-	createdSubscription, err := d.db.CreateSubscription(r.Context(), subscription)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -47,54 +41,41 @@ func (d *Server) handleGetSubscriptionsByUserId(w http.ResponseWriter, r *http.R
 	customerId := r.PathValue("customer_id")
 	customerUUID, err := uuid.Parse(customerId)
 	if err != nil {
-		http.Error(w, "invalid customer_id", http.StatusBadRequest)
+		writeError(w, apperrors.Validation("invalid customer_id"))
 		return
 	}
-
-	page := 1
-	pageSize := 10
-
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if pageSizeStr := r.URL.Query().Get("pageSize"); pageSizeStr != "" {
-		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 {
-			pageSize = ps
-		}
-	}
-
-	pageableRequest := PageableRequest{
-		Page:     page,
-		PageSize: pageSize,
+	pageableRequest, err := d.parsePageableRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	subscriptionsPage, err := d.db.GetSubscriptionsByUserId(r.Context(), pageableRequest, customerUUID.String())
+	subscriptionsPage, err := d.subscriptions.GetSubscriptions(r.Context(), pageableRequest, customerUUID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(subscriptionsPage)
-
+	writePage(w, r, subscriptionsPage, pageableRequest)
 }
 
 func (d *Server) handleCancelSubscription(w http.ResponseWriter, r *http.Request) {
-	customerId := r.PathValue("customer_id")
-	if customerId == "" {
-		http.Error(w, "invalid customer_id", http.StatusBadRequest)
+	customerUUID, err := uuid.Parse(r.PathValue("customer_id"))
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid customer_id"))
 		return
 	}
 	subscriptionId := r.URL.Query().Get("subscription_id")
 	if subscriptionId == "" {
-		http.Error(w, "subscription_id is required", http.StatusBadRequest)
+		writeError(w, apperrors.Validation("subscription_id is required"))
+		return
+	}
+	subscriptionUUID, err := uuid.Parse(subscriptionId)
+	if err != nil {
+		writeError(w, apperrors.Validation("invalid subscription_id"))
 		return
 	}
-	err := d.db.CancelSubscription(r.Context(), subscriptionId, customerId)
+	err = d.subscriptions.Cancel(r.Context(), customerUUID, subscriptionUUID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
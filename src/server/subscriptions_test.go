@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func subscribePath(customerId string) string {
+	return "/customers/" + customerId + "/subscribe"
+}
+
+func TestSubscribeHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	inactive := createPlan(t, s, `{"code": "OLD-30", "name": "Old", "duration_days": 30}`)
+	expectStatus(t, do(t, s, http.MethodPut, "/plans/"+inactive.ID.String(), `{"code": "OLD-30", "name": "Old", "duration_days": 30, "active": false}`), http.StatusOK)
+
+	testCases := []struct {
+		name       string
+		customerId string
+		body       string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"Valid", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q, "start_date": "2099-11-03", "auto_renew": false}`, plan.ID), http.StatusCreated, ""},
+		{"BadCustomerUUID", "not-a-uuid", fmt.Sprintf(`{"plan_id": %q}`, plan.ID), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MalformedJSON", uuid.NewString(), `{"plan_id": `, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadPlanUUID", uuid.NewString(), `{"plan_id": "not-a-uuid"}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MissingPlanId", uuid.NewString(), `{}`, http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"PlanCode", uuid.NewString(), `{"plan_code": "BASIC-30", "start_date": "2099-11-03", "auto_renew": false}`, http.StatusCreated, ""},
+		{"UnknownPlanCode", uuid.NewString(), `{"plan_code": "PREMIUM-30"}`, http.StatusNotFound, apperrors.CodePlanNotFound},
+		{"PlanIdAndCode", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q, "plan_code": "BASIC-30"}`, plan.ID), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"UnknownPlan", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q}`, uuid.New()), http.StatusNotFound, apperrors.CodePlanNotFound},
+		{"InactivePlan", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q}`, inactive.ID), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"PastStartDate", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q, "start_date": "2000-01-01"}`, plan.ID), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadStartDate", uuid.NewString(), fmt.Sprintf(`{"plan_id": %q, "start_date": "03/11/2025"}`, plan.ID), http.StatusBadRequest, apperrors.CodeValidationFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodPost, subscribePath(tc.customerId), tc.body)
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+				return
+			}
+			subscription := decode[Subscription](t, recorder)
+			wantStart := time.Date(2099, 11, 3, 0, 0, 0, 0, time.UTC)
+			if subscription.CustomerID.String() != tc.customerId || subscription.PlanID != plan.ID {
+				t.Errorf("Unexpected subscription %+v", subscription)
+			}
+			if !subscription.StartDate.Equal(wantStart) || !subscription.EndDate.Equal(wantStart.AddDate(0, 0, 30)) {
+				t.Errorf("Expected period %s + 30 days, got %s - %s", wantStart, subscription.StartDate, subscription.EndDate)
+			}
+			if subscription.Status != models.SubscriptionStatusActive || subscription.AutoRenew {
+				t.Errorf("Expected an ACTIVE subscription without auto-renew, got %+v", subscription)
+			}
+		})
+	}
+}
+
+func TestSubscribeHandlerRejectsClientDates(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	body := fmt.Sprintf(`{"plan_id": %q, "end_date": "2099-01-01T00:00:00Z", "status": "EXPIRED"}`, plan.ID)
+	recorder := do(t, s, http.MethodPost, subscribePath(uuid.NewString()), body)
+	expectStatus(t, recorder, http.StatusBadRequest)
+	expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+	fields := decode[errorResponse](t, recorder).Error.Fields
+	if len(fields) != 2 || fields[0].Field != "end_date" || fields[1].Field != "status" {
+		t.Errorf("Expected end_date and status to be reported as unknown, got %+v", fields)
+	}
+}
+
+func TestGetSubscriptionsHandler(t *testing.T) {
+	s, db := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	customerId := uuid.New()
+	for i := 0; i < 3; i++ {
+		_, err := db.CreateSubscription(t.Context(), Subscription{
+			CustomerID: customerId,
+			PlanID:     plan.ID,
+			Status:     models.SubscriptionStatusExpired,
+			CreatedAt:  time.Now().Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		customerId string
+		query      string
+		wantStatus int
+		wantItems  int
+		wantTotal  int64
+	}{
+		{"Defaults", customerId.String(), "", http.StatusOK, 3, 3},
+		{"PageSize", customerId.String(), "?pageSize=2", http.StatusOK, 2, 3},
+		{"SecondPage", customerId.String(), "?page=2&pageSize=2", http.StatusOK, 1, 3},
+		{"InvalidPage", customerId.String(), "?page=x", http.StatusBadRequest, 0, 0},
+		{"ZeroPageSize", customerId.String(), "?pageSize=0", http.StatusBadRequest, 0, 0},
+		{"OtherCustomer", uuid.NewString(), "", http.StatusOK, 0, 0},
+		{"BadCustomerUUID", "not-a-uuid", "", http.StatusBadRequest, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodGet, "/customers/"+tc.customerId+"/subscriptions"+tc.query, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantStatus != http.StatusOK {
+				expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+				return
+			}
+			page := decode[Page[Subscription]](t, recorder)
+			if len(page.Items) != tc.wantItems {
+				t.Errorf("Expected %d items, got %d", tc.wantItems, len(page.Items))
+			}
+			expectTotal(t, page, tc.wantTotal)
+		})
+	}
+}
+
+func TestUnsubscribeHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	customerId := uuid.NewString()
+	recorder := do(t, s, http.MethodPost, subscribePath(customerId), fmt.Sprintf(`{"plan_id": %q}`, plan.ID))
+	expectStatus(t, recorder, http.StatusCreated)
+	subscription := decode[Subscription](t, recorder)
+
+	// Cases run in order: the successful cancel must come before the repeat.
+	testCases := []struct {
+		name       string
+		customerId string
+		query      string
+		wantStatus int
+		wantCode   apperrors.Code
+	}{
+		{"BadCustomerUUID", "not-a-uuid", "?subscription_id=" + subscription.ID.String(), http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"MissingSubscriptionId", customerId, "", http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"BadSubscriptionUUID", customerId, "?subscription_id=not-a-uuid", http.StatusBadRequest, apperrors.CodeValidationFailed},
+		{"OtherCustomer", uuid.NewString(), "?subscription_id=" + subscription.ID.String(), http.StatusNotFound, apperrors.CodeSubscriptionNotFound},
+		{"UnknownSubscription", customerId, "?subscription_id=" + uuid.NewString(), http.StatusNotFound, apperrors.CodeSubscriptionNotFound},
+		{"Valid", customerId, "?subscription_id=" + subscription.ID.String(), http.StatusNoContent, ""},
+		{"AlreadyCancelled", customerId, "?subscription_id=" + subscription.ID.String(), http.StatusNotFound, apperrors.CodeSubscriptionNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := do(t, s, http.MethodPost, "/customers/"+tc.customerId+"/unsubscribe"+tc.query, "")
+			expectStatus(t, recorder, tc.wantStatus)
+			if tc.wantCode != "" {
+				expectErrorCode(t, recorder, tc.wantCode)
+			} else if recorder.Body.Len() != 0 {
+				t.Errorf("Expected an empty body, got %q", recorder.Body.String())
+			}
+		})
+	}
+
+	page := decode[Page[Subscription]](t, do(t, s, http.MethodGet, "/customers/"+customerId+"/subscriptions", ""))
+	if len(page.Items) != 1 || page.Items[0].Status != models.SubscriptionStatusCancelled {
+		t.Errorf("Expected the subscription to be CANCELLED, got %+v", page.Items)
+	}
+	recorder = do(t, s, http.MethodPost, subscribePath(customerId), fmt.Sprintf(`{"plan_id": %q}`, plan.ID))
+	expectStatus(t, recorder, http.StatusCreated)
+}
+
+func TestGetSubscriptionsHandlerCursor(t *testing.T) {
+	s, db := newTestServer(t)
+	plan := createPlan(t, s, validPlanBody)
+	customerId := uuid.New()
+	for i := 0; i < 5; i++ {
+		_, err := db.CreateSubscription(t.Context(), Subscription{
+			CustomerID: customerId,
+			PlanID:     plan.ID,
+			Status:     models.SubscriptionStatusExpired,
+			CreatedAt:  time.Now().Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create subscription: %v", err)
+		}
+	}
+
+	path := "/customers/" + customerId.String() + "/subscriptions?pageSize=2"
+	first := decode[Page[Subscription]](t, do(t, s, http.MethodGet, path, ""))
+	expectTotal(t, first, 5)
+	seen := len(first.Items)
+	cursor := first.NextCursor
+	for cursor != "" {
+		recorder := do(t, s, http.MethodGet, path+"&cursor="+cursor, "")
+		expectStatus(t, recorder, http.StatusOK)
+		if strings.Contains(recorder.Body.String(), "total_count") {
+			t.Errorf("Expected total_count to be omitted in cursor mode: %s", recorder.Body.String())
+		}
+		page := decode[Page[Subscription]](t, recorder)
+		seen += len(page.Items)
+		cursor = page.NextCursor
+	}
+	if seen != 5 {
+		t.Errorf("Expected to page through 5 subscriptions, saw %d", seen)
+	}
+
+	recorder := do(t, s, http.MethodGet, path+"&cursor=garbage", "")
+	expectStatus(t, recorder, http.StatusBadRequest)
+	expectErrorCode(t, recorder, apperrors.CodeValidationFailed)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"bss/src/database/memory"
+	"bss/src/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testCases := []struct {
+		name        string
+		path        string
+		traceparent string
+		wantName    string
+		wantStatus  int
+		wantTraceId string
+		wantParent  string
+	}{
+		{
+			name:       "NewTrace",
+			path:       "/plans",
+			wantName:   "GET /plans",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "ContinuesTraceparent",
+			path:        "/plans/" + uuid.NewString(),
+			traceparent: traceparent,
+			wantName:    "GET /plans/{id}",
+			wantStatus:  http.StatusNotFound,
+			wantTraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParent:  "00f067aa0ba902b7",
+		},
+		{
+			name:       "Unmatched",
+			path:       "/nowhere",
+			wantName:   "GET",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider, exporter := tracing.NewInMemoryProvider()
+			s := newServer(memory.New(), WithTracerProvider(provider))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			recorder := httptest.NewRecorder()
+			s.router.ServeHTTP(recorder, req)
+			expectStatus(t, recorder, tc.wantStatus)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Expected 1 span, got %d", len(spans))
+			}
+			span := spans[0]
+			if span.Name != tc.wantName || span.SpanKind != trace.SpanKindServer {
+				t.Errorf("Expected server span %q, got %s span %q", tc.wantName, span.SpanKind, span.Name)
+			}
+			wantStatusCode := semconv.HTTPResponseStatusCode(tc.wantStatus)
+			if !hasAttribute(span.Attributes, wantStatusCode) {
+				t.Errorf("Expected %v in %v", wantStatusCode, span.Attributes)
+			}
+			if span.Status.Code == codes.Error {
+				t.Errorf("Expected no error status for %d, got %v", tc.wantStatus, span.Status)
+			}
+			if tc.wantTraceId != "" && span.SpanContext.TraceID().String() != tc.wantTraceId {
+				t.Errorf("Expected trace %s, got %s", tc.wantTraceId, span.SpanContext.TraceID())
+			}
+			if parent := span.Parent.SpanID(); tc.wantParent != "" && parent.String() != tc.wantParent {
+				t.Errorf("Expected parent span %s, got %s", tc.wantParent, parent)
+			} else if tc.wantParent == "" && span.Parent.IsValid() {
+				t.Errorf("Expected a root span, got parent %s", parent)
+			}
+		})
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attributes {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultCurrency = "USD"
+
+type PlanService struct {
+	db  Database
+	now func() time.Time
+}
+
+func NewPlanService(db Database) *PlanService {
+	return &PlanService{db: db, now: time.Now}
+}
+
+func (s *PlanService) CreatePlan(ctx context.Context, plan Plan) (Plan, error) {
+	plan = withPlanDefaults(plan)
+	if err := plan.Validate(); err != nil {
+		return Plan{}, err
+	}
+	now := s.now().UTC()
+	plan.CreatedAt = now
+	plan.UpdatedAt = now
+	var createdPlan Plan
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		createdPlan, err = s.db.CreatePlan(ctx, plan)
+		if err != nil {
+			return err
+		}
+		return emit(ctx, s.db, models.PlanCreated{Plan: createdPlan})
+	})
+	return createdPlan, err
+}
+
+// ValidatePlan reports every invalid term of plan the way CreatePlan would,
+// without storing anything.
+func (s *PlanService) ValidatePlan(plan Plan) error {
+	return withPlanDefaults(plan).Validate()
+}
+
+// withPlanDefaults fills in the terms a client may leave out.
+func withPlanDefaults(plan Plan) Plan {
+	if plan.Currency == "" {
+		plan.Currency = defaultCurrency
+	}
+	return plan
+}
+
+func (s *PlanService) GetPlans(ctx context.Context, filter PlanFilter, pageableRequest PageableRequest) (Page[Plan], error) {
+	if filter.MinPriceCents != nil && filter.MaxPriceCents != nil && *filter.MinPriceCents > *filter.MaxPriceCents {
+		return Page[Plan]{}, apperrors.Validation("min_price_cents must not be greater than max_price_cents")
+	}
+	if filter.MinDurationDays != nil && filter.MaxDurationDays != nil && *filter.MinDurationDays > *filter.MaxDurationDays {
+		return Page[Plan]{}, apperrors.Validation("min_duration_days must not be greater than max_duration_days")
+	}
+	return s.db.GetPlans(ctx, filter, pageableRequest)
+}
+
+func (s *PlanService) GetPlan(ctx context.Context, id uuid.UUID) (Plan, error) {
+	return s.db.GetPlan(ctx, id.String())
+}
+
+func (s *PlanService) GetPlanByCode(ctx context.Context, code string) (Plan, error) {
+	if code == "" {
+		return Plan{}, apperrors.Validation("code is required")
+	}
+	return s.db.GetPlanByCode(ctx, code)
+}
+
+// GetPlanVersions returns every version of the plan, newest first.
+func (s *PlanService) GetPlanVersions(ctx context.Context, id uuid.UUID) ([]PlanVersion, error) {
+	return s.db.GetPlanVersions(ctx, id.String())
+}
+
+// UpdatePlanRequest is the body of a full plan update. Active keeps its
+// current value when omitted; id, timestamps and version are always set
+// server-side.
+type UpdatePlanRequest struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	PriceCents   int64  `json:"price_cents"`
+	Currency     string `json:"currency"`
+	DurationDays int    `json:"duration_days"`
+	DataMB       int64  `json:"data_mb"`
+	Active       *bool  `json:"active"`
+	serverManagedPlanFields
+}
+
+// serverManagedPlanFields are accepted in an UpdatePlanRequest, so that a
+// fetched plan can be sent back as is, but ignored.
+type serverManagedPlanFields struct {
+	ID              json.RawMessage `json:"id"`
+	Version         json.RawMessage `json:"version"`
+	CreatedAt       json.RawMessage `json:"created_at"`
+	UpdatedAt       json.RawMessage `json:"updated_at"`
+	RetiredAt       json.RawMessage `json:"retired_at"`
+	SuccessorPlanID json.RawMessage `json:"successor_plan_id"`
+}
+
+// UpdatePlan replaces the plan's terms with req. A non-zero
+// expectedVersion must match the plan's current version.
+func (s *PlanService) UpdatePlan(ctx context.Context, id uuid.UUID, req UpdatePlanRequest, expectedVersion int) (Plan, error) {
+	return s.update(ctx, id, expectedVersion, func(current Plan) (Plan, error) {
+		plan := current
+		plan.Code = req.Code
+		plan.Name = req.Name
+		plan.PriceCents = req.PriceCents
+		plan.Currency = req.Currency
+		plan.DurationDays = req.DurationDays
+		plan.DataMB = req.DataMB
+		if req.Active != nil {
+			plan.Active = *req.Active
+		}
+		return plan, nil
+	})
+}
+
+// PatchPlan applies a JSON Merge Patch (RFC 7396) to the plan. A non-zero
+// expectedVersion must match the plan's current version.
+func (s *PlanService) PatchPlan(ctx context.Context, id uuid.UUID, patch []byte, expectedVersion int) (Plan, error) {
+	return s.update(ctx, id, expectedVersion, func(current Plan) (Plan, error) {
+		return mergePlanPatch(current, patch)
+	})
+}
+
+// update loads the plan, lets change derive its new terms and stores them
+// as the next version, all in one transaction. An update that changes
+// nothing returns the current plan without a new version or event. The version read here is
+// passed on to the database so a concurrent update in between is reported
+// as apperrors.ErrPlanModified rather than silently overwritten.
+func (s *PlanService) update(ctx context.Context, id uuid.UUID, expectedVersion int, change func(current Plan) (Plan, error)) (Plan, error) {
+	var updatedPlan Plan
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.db.GetPlan(ctx, id.String())
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && current.Version != expectedVersion {
+			return apperrors.ErrPlanModified
+		}
+		plan, err := change(current)
+		if err != nil {
+			return err
+		}
+		plan = withPlanDefaults(plan)
+		if err := plan.Validate(); err != nil {
+			return err
+		}
+		if sameTerms(plan, current) {
+			updatedPlan = current
+			return nil
+		}
+		plan.ID = current.ID
+		plan.Version = current.Version
+		plan.CreatedAt = current.CreatedAt
+		plan.UpdatedAt = s.now().UTC()
+		updatedPlan, err = s.db.UpdatePlan(ctx, plan)
+		if err != nil {
+			return err
+		}
+		return emit(ctx, s.db, models.PlanUpdated{Plan: updatedPlan})
+	})
+	return updatedPlan, err
+}
+
+// sameTerms reports whether an update would leave the plan's client supplied
+// terms as they are, in which case no new version is written.
+func sameTerms(a, b Plan) bool {
+	return a.Code == b.Code && a.Name == b.Name && a.PriceCents == b.PriceCents &&
+		a.Currency == b.Currency && a.DurationDays == b.DurationDays && a.DataMB == b.DataMB &&
+		a.Active == b.Active
+}
+
+// readOnlyPlanFields are managed by the server, or by RetirePlan, and cannot
+// be patched.
+var readOnlyPlanFields = []string{"id", "version", "created_at", "updated_at", "retired_at", "successor_plan_id"}
+
+// mergePlanPatch applies patch to current. Plans have no nested objects, so
+// the merge is a single level: members replace the current value and null
+// members reset it.
+func mergePlanPatch(current Plan, patch []byte) (Plan, error) {
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return Plan{}, apperrors.Validation("patch must be a JSON object")
+	}
+	for _, field := range readOnlyPlanFields {
+		if _, ok := changes[field]; ok {
+			return Plan{}, apperrors.Validation("%s is read-only", field)
+		}
+	}
+	document, err := json.Marshal(current)
+	if err != nil {
+		return Plan{}, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(document, &merged); err != nil {
+		return Plan{}, err
+	}
+	for field, value := range changes {
+		if string(value) == "null" {
+			delete(merged, field)
+		} else {
+			merged[field] = value
+		}
+	}
+	if document, err = json.Marshal(merged); err != nil {
+		return Plan{}, err
+	}
+	var plan Plan
+	if err := models.DecodeJSON(bytes.NewReader(document), &plan); err != nil {
+		return Plan{}, err
+	}
+	return plan, nil
+}
+
+// RetirePlanRequest is the body of a plan retirement. EffectiveDate defaults
+// to now; SuccessorPlanID is optional.
+type RetirePlanRequest struct {
+	EffectiveDate   string     `json:"effective_date"`
+	SuccessorPlanID *uuid.UUID `json:"successor_plan_id"`
+}
+
+// RetirePlan schedules the plan's retirement. From the effective date, at
+// midnight UTC, the plan can no longer be subscribed to and auto-renewals
+// move to the successor, or expire when there is none. Retiring an already
+// retired plan reschedules it.
+func (s *PlanService) RetirePlan(ctx context.Context, id uuid.UUID, req RetirePlanRequest) (Plan, error) {
+	now := s.now().UTC()
+	retiredAt := now
+	if req.EffectiveDate != "" {
+		parsed, err := time.Parse(dateLayout, req.EffectiveDate)
+		if err != nil {
+			return Plan{}, apperrors.Validation("effective_date must be a date in YYYY-MM-DD format")
+		}
+		retiredAt = parsed
+	}
+	if req.SuccessorPlanID != nil && *req.SuccessorPlanID == id {
+		return Plan{}, apperrors.Validation("a plan cannot be its own successor")
+	}
+	var retiredPlan Plan
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		plan, err := s.db.GetPlan(ctx, id.String())
+		if err != nil {
+			return err
+		}
+		if req.SuccessorPlanID != nil {
+			if err := s.validateSuccessor(ctx, *req.SuccessorPlanID); err != nil {
+				return err
+			}
+		}
+		plan.RetiredAt = &retiredAt
+		plan.SuccessorPlanID = req.SuccessorPlanID
+		plan.UpdatedAt = now
+		retiredPlan, err = s.db.RetirePlan(ctx, plan)
+		if err != nil {
+			return err
+		}
+		return emit(ctx, s.db, models.PlanRetired{Plan: retiredPlan})
+	})
+	return retiredPlan, err
+}
+
+// validateSuccessor checks that subscribers can be moved to the plan with
+// the given id. Retired plans are refused, which also keeps successors from
+// forming a cycle.
+func (s *PlanService) validateSuccessor(ctx context.Context, id uuid.UUID) error {
+	successor, err := s.db.GetPlan(ctx, id.String())
+	if errors.Is(err, apperrors.ErrPlanNotFound) {
+		return apperrors.Validation("successor plan %s does not exist", id)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case !successor.Active:
+		return apperrors.Validation("successor plan is not active")
+	case successor.RetiredAt != nil:
+		return apperrors.Validation("successor plan is retired")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bss/src/apperrors"
+	"bss/src/database/memory"
+	"bss/src/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMergePlanPatch(t *testing.T) {
+	current := Plan{Code: "RM-UL-30D", Name: "Unlimited 30 Days", PriceCents: 1999, Currency: "EUR", DurationDays: 30, DataMB: 30720, Active: true, Version: 4}
+
+	testCases := []struct {
+		name    string
+		patch   string
+		want    func(p *Plan)
+		wantErr bool
+	}{
+		{"Empty", `{}`, func(p *Plan) {}, false},
+		{"ChangePrice", `{"price_cents": 2499}`, func(p *Plan) { p.PriceCents = 2499 }, false},
+		{"Deactivate", `{"active": false}`, func(p *Plan) { p.Active = false }, false},
+		{"NullResets", `{"currency": null, "data_mb": null}`, func(p *Plan) { p.Currency = ""; p.DataMB = 0 }, false},
+		{"UnknownField", `{"colour": "red"}`, nil, true},
+		{"NotAnObject", `[{"price_cents": 1}]`, nil, true},
+		{"Null", `null`, nil, true},
+		{"Malformed", `{"price_cents": `, nil, true},
+		{"WrongType", `{"price_cents": "cheap"}`, nil, true},
+		{"ReadOnlyVersion", `{"version": 9}`, nil, true},
+		{"ReadOnlyId", `{"id": "3f1c7c4e-0a7e-4d55-9c1a-6a1f0b3e2d11"}`, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mergePlanPatch(current, []byte(tc.patch))
+			if tc.wantErr {
+				if apperrors.CodeOf(err) != apperrors.CodeValidationFailed {
+					t.Errorf("Expected a validation error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			want := current
+			tc.want(&want)
+			if got != want {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestUpdatePlanWithoutChanges(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	plans := NewPlanService(db)
+	plan, err := plans.CreatePlan(ctx, Plan{Code: "BASIC-30", Name: "Basic", PriceCents: 999, DurationDays: 30})
+	if err != nil {
+		t.Fatalf("Failed to create plan: %v", err)
+	}
+	same := UpdatePlanRequest{Code: plan.Code, Name: plan.Name, PriceCents: plan.PriceCents, Currency: plan.Currency, DurationDays: plan.DurationDays}
+
+	testCases := []struct {
+		name        string
+		update      func() (Plan, error)
+		wantVersion int
+	}{
+		{"EmptyPatch", func() (Plan, error) { return plans.PatchPlan(ctx, plan.ID, []byte(`{}`), 0) }, 1},
+		{"SameValuePatch", func() (Plan, error) { return plans.PatchPlan(ctx, plan.ID, []byte(`{"price_cents": 999}`), 1) }, 1},
+		{"IdenticalPut", func() (Plan, error) { return plans.UpdatePlan(ctx, plan.ID, same, 1) }, 1},
+		{"Change", func() (Plan, error) { return plans.PatchPlan(ctx, plan.ID, []byte(`{"price_cents": 1299}`), 1) }, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated, err := tc.update()
+			if err != nil {
+				t.Fatalf("Failed to update plan: %v", err)
+			}
+			if updated.Version != tc.wantVersion {
+				t.Errorf("Expected version %d, got %d", tc.wantVersion, updated.Version)
+			}
+			versions, err := plans.GetPlanVersions(ctx, plan.ID)
+			if err != nil {
+				t.Fatalf("Failed to get versions: %v", err)
+			}
+			if len(versions) != tc.wantVersion {
+				t.Errorf("Expected %d versions, got %d", tc.wantVersion, len(versions))
+			}
+			var updates int
+			err = db.StreamEvents(ctx, models.EventFilter{ResourceID: &plan.ID, EventType: models.EventTypePlanUpdated}, func(Event) error {
+				updates++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to stream events: %v", err)
+			}
+			if updates != tc.wantVersion-1 {
+				t.Errorf("Expected %d plan.updated events, got %d", tc.wantVersion-1, updates)
+			}
+		})
+	}
+}
+
+func TestRetirePlan(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	plans := NewPlanService(db)
+	create := func(code string) Plan {
+		plan, err := plans.CreatePlan(ctx, Plan{Code: code, Name: code, DurationDays: 30})
+		if err != nil {
+			t.Fatalf("Failed to create plan: %v", err)
+		}
+		return plan
+	}
+	plan := create("OLD-30")
+	successor := create("NEW-30")
+	inactive := create("GONE-30")
+	if _, err := plans.PatchPlan(ctx, inactive.ID, []byte(`{"active": false}`), 0); err != nil {
+		t.Fatalf("Failed to deactivate plan: %v", err)
+	}
+	retired := create("RETIRED-30")
+	if _, err := plans.RetirePlan(ctx, retired.ID, RetirePlanRequest{}); err != nil {
+		t.Fatalf("Failed to retire plan: %v", err)
+	}
+	unknown := uuid.New()
+
+	testCases := []struct {
+		name     string
+		id       uuid.UUID
+		req      RetirePlanRequest
+		wantCode apperrors.Code
+	}{
+		{"BadEffectiveDate", plan.ID, RetirePlanRequest{EffectiveDate: "15/01/2025"}, apperrors.CodeValidationFailed},
+		{"OwnSuccessor", plan.ID, RetirePlanRequest{SuccessorPlanID: &plan.ID}, apperrors.CodeValidationFailed},
+		{"UnknownSuccessor", plan.ID, RetirePlanRequest{SuccessorPlanID: &unknown}, apperrors.CodeValidationFailed},
+		{"InactiveSuccessor", plan.ID, RetirePlanRequest{SuccessorPlanID: &inactive.ID}, apperrors.CodeValidationFailed},
+		{"RetiredSuccessor", plan.ID, RetirePlanRequest{SuccessorPlanID: &retired.ID}, apperrors.CodeValidationFailed},
+		{"UnknownPlan", unknown, RetirePlanRequest{}, apperrors.CodePlanNotFound},
+		{"Valid", plan.ID, RetirePlanRequest{EffectiveDate: "2025-01-15", SuccessorPlanID: &successor.ID}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := plans.RetirePlan(ctx, tc.id, tc.req)
+			if tc.wantCode != "" {
+				if apperrors.CodeOf(err) != tc.wantCode {
+					t.Errorf("Expected %s, got %v", tc.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if want := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC); got.RetiredAt == nil || !got.RetiredAt.Equal(want) {
+				t.Errorf("Expected retirement at %v, got %v", want, got.RetiredAt)
+			}
+			if got.SuccessorPlanID == nil || *got.SuccessorPlanID != successor.ID || got.Version != plan.Version {
+				t.Errorf("Unexpected retired plan %+v", got)
+			}
+		})
+	}
+}
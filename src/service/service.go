@@ -0,0 +1,53 @@
+// Package service holds the business rules of the plan and subscription
+// domains: validation, date math, transactions and event emission. Handlers
+// and background jobs go through it instead of calling the database directly.
+package service
+
+import (
+	"bss/src/models"
+	"context"
+	"time"
+)
+
+type PageableRequest = models.PageableRequest
+type Page[V any] = models.Page[V]
+type Plan = models.Plan
+type PlanVersion = models.PlanVersion
+type PlanFilter = models.PlanFilter
+type Subscription = models.Subscription
+type SubscriptionStatus = models.SubscriptionStatus
+type Event = models.Event
+
+// Database is the persistence the services build on. Implementations must
+// make every method called with the context handed to WithinTx's fn part of
+// one transaction.
+type Database interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+
+	CreatePlan(ctx context.Context, plan Plan) (Plan, error)
+	GetPlans(ctx context.Context, filter PlanFilter, pageableRequest PageableRequest) (Page[Plan], error)
+	GetPlan(ctx context.Context, id string) (Plan, error)
+	GetPlanByCode(ctx context.Context, code string) (Plan, error)
+	UpdatePlan(ctx context.Context, plan Plan) (Plan, error)
+	GetPlanVersions(ctx context.Context, planId string) ([]PlanVersion, error)
+	GetPlanVersion(ctx context.Context, planId string, version int) (PlanVersion, error)
+	RetirePlan(ctx context.Context, plan Plan) (Plan, error)
+
+	CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	GetSubscriptionsByUserId(ctx context.Context, pageableRequest PageableRequest, userId string) (Page[Subscription], error)
+	CancelSubscription(ctx context.Context, id string, custId string) (Subscription, error)
+	UpdateSubscriptionStatus(ctx context.Context, id string, status SubscriptionStatus) (Subscription, error)
+	ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]Subscription, error)
+	LockDueRenewals(ctx context.Context, now time.Time, limit int) ([]Subscription, error)
+
+	CreateEvent(ctx context.Context, event Event) error
+}
+
+// emit records payload in the outbox using ctx's transaction.
+func emit(ctx context.Context, db Database, payload models.EventPayload) error {
+	event, err := models.NewEvent(payload)
+	if err != nil {
+		return err
+	}
+	return db.CreateEvent(ctx, event)
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"bss/src/apperrors"
+	"bss/src/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const dateLayout = "2006-01-02"
+
+// SubscribeRequest is the body of a subscribe call. The plan is given by
+// either PlanID or PlanCode. Dates, status and timestamps are always
+// computed server-side from the plan.
+type SubscribeRequest struct {
+	PlanID    uuid.UUID `json:"plan_id"`
+	PlanCode  string    `json:"plan_code"`
+	StartDate string    `json:"start_date"`
+	AutoRenew *bool     `json:"auto_renew"`
+}
+
+// RenewalVersionPolicy decides which plan version an auto-renewal is bought
+// on.
+type RenewalVersionPolicy string
+
+const (
+	// RenewOnLatestVersion moves subscribers to the plan's current terms.
+	RenewOnLatestVersion RenewalVersionPolicy = "latest"
+	// RenewOnSameVersion keeps subscribers on the version they bought.
+	RenewOnSameVersion RenewalVersionPolicy = "same"
+)
+
+func ParseRenewalVersionPolicy(s string) (RenewalVersionPolicy, error) {
+	switch policy := RenewalVersionPolicy(s); policy {
+	case RenewOnLatestVersion, RenewOnSameVersion:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown renewal version policy %q", s)
+}
+
+// SubscriptionMetrics is told about subscriptions customers start or cancel,
+// once the change has been committed.
+type SubscriptionMetrics interface {
+	SubscriptionCreated(subscription Subscription)
+	SubscriptionCancelled(subscription Subscription)
+}
+
+type noMetrics struct{}
+
+func (noMetrics) SubscriptionCreated(Subscription)   {}
+func (noMetrics) SubscriptionCancelled(Subscription) {}
+
+type SubscriptionService struct {
+	db            Database
+	now           func() time.Time
+	renewalPolicy RenewalVersionPolicy
+	metrics       SubscriptionMetrics
+}
+
+// SubscriptionOption configures optional SubscriptionService behaviour.
+type SubscriptionOption func(*SubscriptionService)
+
+// WithRenewalVersionPolicy sets the plan version renewals are bought on.
+// The default is RenewOnLatestVersion.
+func WithRenewalVersionPolicy(policy RenewalVersionPolicy) SubscriptionOption {
+	return func(s *SubscriptionService) {
+		s.renewalPolicy = policy
+	}
+}
+
+// WithSubscriptionMetrics reports subscriptions started and cancelled
+// through the service to metrics.
+func WithSubscriptionMetrics(metrics SubscriptionMetrics) SubscriptionOption {
+	return func(s *SubscriptionService) {
+		s.metrics = metrics
+	}
+}
+
+func NewSubscriptionService(db Database, opts ...SubscriptionOption) *SubscriptionService {
+	s := &SubscriptionService{db: db, now: time.Now, renewalPolicy: RenewOnLatestVersion, metrics: noMetrics{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// validate reports every invalid field of req.
+func (req SubscribeRequest) validate() error {
+	var fields apperrors.FieldErrors
+	switch {
+	case req.PlanID == uuid.Nil && req.PlanCode == "":
+		fields.Add("plan_id", "or plan_code is required")
+	case req.PlanID != uuid.Nil && req.PlanCode != "":
+		fields.Add("plan_code", "must not be given together with plan_id")
+	}
+	if req.StartDate != "" {
+		if _, err := time.Parse(dateLayout, req.StartDate); err != nil {
+			fields.Add("start_date", "must be a date in YYYY-MM-DD format")
+		}
+	}
+	return fields.Err()
+}
+
+// newSubscription builds the subscription for req. The period starts at now,
+// or at midnight UTC of req.StartDate when given, and lasts the plan's
+// DurationDays. Start dates before today are rejected: a back-dated period
+// would already be due, and the renewal sweep would then open and bill one
+// catch-up period after another until it reached today.
+func newSubscription(customerId uuid.UUID, plan Plan, req SubscribeRequest, now time.Time) (Subscription, error) {
+	if !plan.Active {
+		return Subscription{}, apperrors.Validation("plan is not active")
+	}
+	if plan.RetiredBy(now) {
+		return Subscription{}, apperrors.Validation("plan is retired")
+	}
+	if plan.DurationDays <= 0 {
+		return Subscription{}, apperrors.Validation("plan has no valid duration")
+	}
+	startDate := now
+	if req.StartDate != "" {
+		parsed, err := time.Parse(dateLayout, req.StartDate)
+		if err != nil {
+			return Subscription{}, apperrors.Validation("start_date must be a date in YYYY-MM-DD format")
+		}
+		if today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC); parsed.Before(today) {
+			var fields apperrors.FieldErrors
+			fields.Add("start_date", "must not be before today")
+			return Subscription{}, fields.Err()
+		}
+		startDate = parsed
+	}
+	autoRenew := true
+	if req.AutoRenew != nil {
+		autoRenew = *req.AutoRenew
+	}
+	subscription := Subscription{
+		CustomerID:  customerId,
+		PlanID:      plan.ID,
+		PlanVersion: plan.Version,
+		StartDate:   startDate,
+		EndDate:     startDate.AddDate(0, 0, plan.DurationDays),
+		Status:      models.SubscriptionStatusActive,
+		AutoRenew:   autoRenew,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := subscription.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	return subscription, nil
+}
+
+// renewalOf returns the period following previous on the given plan
+// version, starting at previous' end date and lasting the version's
+// DurationDays.
+func renewalOf(previous Subscription, version PlanVersion, now time.Time) Subscription {
+	return Subscription{
+		CustomerID:  previous.CustomerID,
+		PlanID:      version.PlanID,
+		PlanVersion: version.Version,
+		StartDate:   previous.EndDate,
+		EndDate:     previous.EndDate.AddDate(0, 0, version.DurationDays),
+		Status:      models.SubscriptionStatusActive,
+		AutoRenew:   true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		RenewedFrom: &previous.ID,
+	}
+}
+
+func (s *SubscriptionService) Subscribe(ctx context.Context, customerId uuid.UUID, req SubscribeRequest) (Subscription, error) {
+	if err := req.validate(); err != nil {
+		return Subscription{}, err
+	}
+	var createdSubscription Subscription
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		plan, err := s.subscribedPlan(ctx, req)
+		if err != nil {
+			return err
+		}
+		subscription, err := newSubscription(customerId, plan, req, s.now().UTC())
+		if err != nil {
+			return err
+		}
+		createdSubscription, err = s.db.CreateSubscription(ctx, subscription)
+		if err != nil {
+			return err
+		}
+		return emit(ctx, s.db, models.SubscriptionCreated{Subscription: createdSubscription})
+	})
+	if err != nil {
+		return Subscription{}, err
+	}
+	s.metrics.SubscriptionCreated(createdSubscription)
+	return createdSubscription, nil
+}
+
+// subscribedPlan loads the plan req refers to.
+func (s *SubscriptionService) subscribedPlan(ctx context.Context, req SubscribeRequest) (Plan, error) {
+	if req.PlanCode != "" {
+		return s.db.GetPlanByCode(ctx, req.PlanCode)
+	}
+	return s.db.GetPlan(ctx, req.PlanID.String())
+}
+
+func (s *SubscriptionService) GetSubscriptions(ctx context.Context, pageableRequest PageableRequest, customerId uuid.UUID) (Page[Subscription], error) {
+	return s.db.GetSubscriptionsByUserId(ctx, pageableRequest, customerId.String())
+}
+
+// Cancel cancels the customer's subscription. Subscriptions belonging to
+// another customer are reported as not found.
+func (s *SubscriptionService) Cancel(ctx context.Context, customerId uuid.UUID, subscriptionId uuid.UUID) error {
+	var cancelled Subscription
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		subscription, err := s.db.CancelSubscription(ctx, subscriptionId.String(), customerId.String())
+		if err != nil {
+			return err
+		}
+		cancelled = subscription
+		return emit(ctx, s.db, models.SubscriptionCancelled{Subscription: subscription})
+	})
+	if err != nil {
+		return err
+	}
+	s.metrics.SubscriptionCancelled(cancelled)
+	return nil
+}
+
+// ExpireSubscriptions expires up to limit subscriptions past their end date
+// and records a subscription.expired event for each.
+func (s *SubscriptionService) ExpireSubscriptions(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	var expired []Subscription
+	err := s.db.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		expired, err = s.db.ExpireSubscriptions(ctx, now, limit)
+		if err != nil {
+			return err
+		}
+		for _, subscription := range expired {
+			if err := emit(ctx, s.db, models.SubscriptionExpired{Subscription: subscription}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// renewalVersion picks the plan version previous renews onto. While the
+// plan is not retired by the renewal the renewal policy decides. Otherwise
+// the subscriber migrates to the current version of its successor, following
+// successors that are retired in turn; ok is false when that chain ends
+// without an active plan to move to.
+func (s *SubscriptionService) renewalVersion(ctx context.Context, previous Subscription) (version PlanVersion, ok bool, err error) {
+	plan, err := s.db.GetPlan(ctx, previous.PlanID.String())
+	if err != nil {
+		return PlanVersion{}, false, err
+	}
+	if !plan.RetiredBy(previous.EndDate) {
+		if s.renewalPolicy == RenewOnSameVersion {
+			version, err = s.db.GetPlanVersion(ctx, previous.PlanID.String(), previous.PlanVersion)
+			return version, err == nil, err
+		}
+		return plan.CurrentVersion(), true, nil
+	}
+	seen := map[uuid.UUID]bool{}
+	for plan.RetiredBy(previous.EndDate) {
+		if plan.SuccessorPlanID == nil || seen[plan.ID] {
+			return PlanVersion{}, false, nil
+		}
+		seen[plan.ID] = true
+		if plan, err = s.db.GetPlan(ctx, plan.SuccessorPlanID.String()); err != nil {
+			return PlanVersion{}, false, err
+		}
+	}
+	return plan.CurrentVersion(), plan.Active, nil
+}
+
+// RenewSubscriptions closes up to limit due auto-renew subscriptions on
+// active plans and opens the next period for each. The old subscription is
+// marked EXPIRED and a subscription.renewed event is recorded in the same
+// transaction. Subscribers of a retired plan are migrated to its successor,
+// or just expired when there is nothing left to migrate to. A period is
+// renewed at most once, so a sweep that crashed half way can simply be
+// rerun. processed counts every due subscription the batch handled, renewed
+// or not, so that callers know when the queue is drained.
+func (s *SubscriptionService) RenewSubscriptions(ctx context.Context, now time.Time, limit int) (renewed []Subscription, processed int, err error) {
+	err = s.db.WithinTx(ctx, func(ctx context.Context) error {
+		due, err := s.db.LockDueRenewals(ctx, now, limit)
+		if err != nil {
+			return err
+		}
+		processed = len(due)
+		for _, previous := range due {
+			version, ok, err := s.renewalVersion(ctx, previous)
+			if err != nil {
+				return err
+			}
+			expired, err := s.db.UpdateSubscriptionStatus(ctx, previous.ID.String(), models.SubscriptionStatusExpired)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := emit(ctx, s.db, models.SubscriptionExpired{Subscription: expired}); err != nil {
+					return err
+				}
+				continue
+			}
+			next, err := s.db.CreateSubscription(ctx, renewalOf(previous, version, s.now().UTC()))
+			if errors.Is(err, apperrors.ErrSubscriptionAlreadyRenewed) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			event := models.SubscriptionRenewed{Subscription: next, PreviousSubscriptionID: previous.ID}
+			if err := emit(ctx, s.db, event); err != nil {
+				return err
+			}
+			renewed = append(renewed, next)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return renewed, processed, nil
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"bss/src/apperrors"
+	"bss/src/database/memory"
+	"bss/src/models"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewSubscription(t *testing.T) {
+	now := time.Date(2025, 11, 3, 9, 30, 0, 0, time.UTC)
+	plan := Plan{ID: uuid.New(), DurationDays: 30, Active: true}
+	customerId := uuid.New()
+	autoRenewOff := false
+	nextMonth := now.AddDate(0, 1, 0)
+
+	testCases := []struct {
+		name          string
+		plan          Plan
+		req           SubscribeRequest
+		wantStart     time.Time
+		wantEnd       time.Time
+		wantAutoRenew bool
+		wantErr       bool
+		wantFields    []string
+	}{
+		{
+			name:          "DefaultsToNow",
+			plan:          plan,
+			wantStart:     now,
+			wantEnd:       now.AddDate(0, 0, 30),
+			wantAutoRenew: true,
+		},
+		{
+			name:          "ExplicitStartDate",
+			plan:          plan,
+			req:           SubscribeRequest{StartDate: "2025-12-01", AutoRenew: &autoRenewOff},
+			wantStart:     time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:       time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+			wantAutoRenew: false,
+		},
+		{
+			name:          "StartsToday",
+			plan:          plan,
+			req:           SubscribeRequest{StartDate: "2025-11-03"},
+			wantStart:     time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC),
+			wantEnd:       time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC),
+			wantAutoRenew: true,
+		},
+		{
+			name:       "PastStartDate",
+			plan:       plan,
+			req:        SubscribeRequest{StartDate: "2025-11-02"},
+			wantErr:    true,
+			wantFields: []string{"start_date"},
+		},
+		{
+			name:    "InvalidStartDate",
+			plan:    plan,
+			req:     SubscribeRequest{StartDate: "03/11/2025"},
+			wantErr: true,
+		},
+		{
+			name:    "InactivePlan",
+			plan:    Plan{ID: plan.ID, DurationDays: 30},
+			wantErr: true,
+		},
+		{
+			name:    "RetiredPlan",
+			plan:    Plan{ID: plan.ID, DurationDays: 30, Active: true, RetiredAt: &now},
+			wantErr: true,
+		},
+		{
+			name:          "PlanRetiringLater",
+			plan:          Plan{ID: plan.ID, DurationDays: 30, Active: true, RetiredAt: &nextMonth},
+			wantStart:     now,
+			wantEnd:       now.AddDate(0, 0, 30),
+			wantAutoRenew: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subscription, err := newSubscription(customerId, tc.plan, tc.req, now)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", subscription)
+				}
+				if tc.wantFields != nil {
+					var appErr *apperrors.Error
+					if !errors.As(err, &appErr) || appErr.Code != apperrors.CodeValidationFailed {
+						t.Fatalf("Expected a validation error, got %v", err)
+					}
+					var fields []string
+					for _, field := range appErr.Fields {
+						fields = append(fields, field.Field)
+					}
+					if !slices.Equal(fields, tc.wantFields) {
+						t.Errorf("Expected invalid fields %v, got %v", tc.wantFields, fields)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !subscription.StartDate.Equal(tc.wantStart) || !subscription.EndDate.Equal(tc.wantEnd) {
+				t.Errorf("Expected period %v - %v, got %v - %v", tc.wantStart, tc.wantEnd, subscription.StartDate, subscription.EndDate)
+			}
+			if subscription.Status != "ACTIVE" {
+				t.Errorf("Expected status ACTIVE, got %s", subscription.Status)
+			}
+			if subscription.AutoRenew != tc.wantAutoRenew {
+				t.Errorf("Expected auto_renew=%v, got %v", tc.wantAutoRenew, subscription.AutoRenew)
+			}
+			if subscription.CustomerID != customerId || subscription.PlanID != plan.ID {
+				t.Errorf("Unexpected customer or plan on %+v", subscription)
+			}
+		})
+	}
+}
+
+func TestRenewalOf(t *testing.T) {
+	now := time.Date(2025, 12, 3, 0, 5, 0, 0, time.UTC)
+	previous := Subscription{
+		ID:         uuid.New(),
+		CustomerID: uuid.New(),
+		PlanID:     uuid.New(),
+		StartDate:  time.Date(2025, 11, 3, 0, 0, 0, 0, time.UTC),
+		EndDate:    time.Date(2025, 12, 3, 0, 0, 0, 0, time.UTC),
+		Status:     "ACTIVE",
+		AutoRenew:  true,
+	}
+	plan := Plan{ID: previous.PlanID, DurationDays: 7, Active: true, Version: 3}
+
+	next := renewalOf(previous, plan.CurrentVersion(), now)
+	if !next.StartDate.Equal(previous.EndDate) {
+		t.Errorf("Expected renewal to start at %v, got %v", previous.EndDate, next.StartDate)
+	}
+	if want := time.Date(2025, 12, 10, 0, 0, 0, 0, time.UTC); !next.EndDate.Equal(want) {
+		t.Errorf("Expected renewal to end at %v, got %v", want, next.EndDate)
+	}
+	if next.RenewedFrom == nil || *next.RenewedFrom != previous.ID {
+		t.Errorf("Expected renewal to reference %s, got %v", previous.ID, next.RenewedFrom)
+	}
+	if next.CustomerID != previous.CustomerID || next.Status != "ACTIVE" || next.PlanVersion != 3 {
+		t.Errorf("Unexpected renewal %+v", next)
+	}
+}
+
+func TestRenewSubscriptionsVersionPolicy(t *testing.T) {
+	testCases := []struct {
+		policy       RenewalVersionPolicy
+		wantVersion  int
+		wantDuration int
+	}{
+		{RenewOnLatestVersion, 2, 7},
+		{RenewOnSameVersion, 1, 30},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			ctx := context.Background()
+			db := memory.New()
+			plans := NewPlanService(db)
+			subscriptions := NewSubscriptionService(db, WithRenewalVersionPolicy(tc.policy))
+			subscriptions.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+			plan, err := plans.CreatePlan(ctx, Plan{Code: "BASIC-30", Name: "Basic", PriceCents: 999, DurationDays: 30})
+			if err != nil {
+				t.Fatalf("Failed to create plan: %v", err)
+			}
+			subscription, err := subscriptions.Subscribe(ctx, uuid.New(), SubscribeRequest{PlanID: plan.ID})
+			if err != nil {
+				t.Fatalf("Failed to subscribe: %v", err)
+			}
+			if subscription.PlanVersion != 1 {
+				t.Fatalf("Expected the subscription to be on version 1, got %d", subscription.PlanVersion)
+			}
+			if _, err := plans.PatchPlan(ctx, plan.ID, []byte(`{"price_cents": 1299, "duration_days": 7}`), 0); err != nil {
+				t.Fatalf("Failed to update plan: %v", err)
+			}
+
+			renewed, _, err := subscriptions.RenewSubscriptions(ctx, subscription.EndDate, 10)
+			if err != nil {
+				t.Fatalf("Failed to renew: %v", err)
+			}
+			if len(renewed) != 1 {
+				t.Fatalf("Expected one renewal, got %d", len(renewed))
+			}
+			next := renewed[0]
+			if next.PlanVersion != tc.wantVersion {
+				t.Errorf("Expected renewal on version %d, got %d", tc.wantVersion, next.PlanVersion)
+			}
+			if want := subscription.EndDate.AddDate(0, 0, tc.wantDuration); !next.EndDate.Equal(want) {
+				t.Errorf("Expected renewal to end at %v, got %v", want, next.EndDate)
+			}
+		})
+	}
+}
+
+func TestRenewSubscriptionsRetiredPlan(t *testing.T) {
+	testCases := []struct {
+		name              string
+		effectiveDate     string
+		withSuccessor     bool
+		retireSuccessor   bool
+		wantPlan          string
+		wantExpiredEvents int
+	}{
+		{"MigratesToSuccessor", "2025-01-15", true, false, "NEW-30", 0},
+		{"ExpiresWithoutSuccessor", "2025-01-15", false, false, "", 1},
+		{"FollowsRetiredSuccessor", "2025-01-15", true, true, "NEWEST-30", 0},
+		{"RenewsBeforeEffectiveDate", "2099-01-01", true, false, "OLD-30", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := memory.New()
+			plans := NewPlanService(db)
+			subscriptions := NewSubscriptionService(db)
+			subscriptions.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+			create := func(code string, durationDays int) Plan {
+				plan, err := plans.CreatePlan(ctx, Plan{Code: code, Name: code, PriceCents: 999, DurationDays: durationDays})
+				if err != nil {
+					t.Fatalf("Failed to create plan: %v", err)
+				}
+				return plan
+			}
+			old := create("OLD-30", 30)
+			successor := create("NEW-30", 7)
+			newest := create("NEWEST-30", 14)
+			subscription, err := subscriptions.Subscribe(ctx, uuid.New(), SubscribeRequest{PlanID: old.ID})
+			if err != nil {
+				t.Fatalf("Failed to subscribe: %v", err)
+			}
+
+			req := RetirePlanRequest{EffectiveDate: tc.effectiveDate}
+			if tc.withSuccessor {
+				req.SuccessorPlanID = &successor.ID
+			}
+			if _, err := plans.RetirePlan(ctx, old.ID, req); err != nil {
+				t.Fatalf("Failed to retire plan: %v", err)
+			}
+			if tc.retireSuccessor {
+				if _, err := plans.RetirePlan(ctx, successor.ID, RetirePlanRequest{EffectiveDate: "2025-01-01", SuccessorPlanID: &newest.ID}); err != nil {
+					t.Fatalf("Failed to retire successor: %v", err)
+				}
+			}
+
+			// Expiry and renewal run as separate scheduler jobs; sweep both.
+			if _, err := subscriptions.ExpireSubscriptions(ctx, subscription.EndDate, 10); err != nil {
+				t.Fatalf("Failed to expire: %v", err)
+			}
+			renewed, _, err := subscriptions.RenewSubscriptions(ctx, subscription.EndDate, 10)
+			if err != nil {
+				t.Fatalf("Failed to renew: %v", err)
+			}
+			if tc.wantPlan == "" {
+				if len(renewed) != 0 {
+					t.Errorf("Expected no renewal, got %+v", renewed)
+				}
+			} else {
+				if len(renewed) != 1 {
+					t.Fatalf("Expected one renewal, got %d", len(renewed))
+				}
+				plan, err := plans.GetPlan(ctx, renewed[0].PlanID)
+				if err != nil {
+					t.Fatalf("Failed to get plan: %v", err)
+				}
+				if plan.Code != tc.wantPlan {
+					t.Errorf("Expected renewal onto %s, got %s", tc.wantPlan, plan.Code)
+				}
+				if want := subscription.EndDate.AddDate(0, 0, plan.DurationDays); !renewed[0].EndDate.Equal(want) {
+					t.Errorf("Expected renewal to end at %v, got %v", want, renewed[0].EndDate)
+				}
+			}
+
+			var expiredEvents, retiredEvents int
+			err = db.StreamEvents(ctx, models.EventFilter{}, func(event Event) error {
+				switch event.EventType {
+				case models.EventTypeSubscriptionExpired:
+					expiredEvents++
+				case models.EventTypePlanRetired:
+					retiredEvents++
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Failed to stream events: %v", err)
+			}
+			if expiredEvents != tc.wantExpiredEvents {
+				t.Errorf("Expected %d subscription.expired events, got %d", tc.wantExpiredEvents, expiredEvents)
+			}
+			if wantRetired := map[bool]int{false: 1, true: 2}[tc.retireSuccessor]; retiredEvents != wantRetired {
+				t.Errorf("Expected %d plan.retired events, got %d", wantRetired, retiredEvents)
+			}
+		})
+	}
+}
+
+func TestSubscribeRequestValidate(t *testing.T) {
+	planId := uuid.New()
+	testCases := []struct {
+		name       string
+		req        SubscribeRequest
+		wantFields []string
+	}{
+		{"PlanId", SubscribeRequest{PlanID: planId, StartDate: "2025-11-03"}, nil},
+		{"PlanCode", SubscribeRequest{PlanCode: "BASIC-MONTHLY"}, nil},
+		{"NoPlan", SubscribeRequest{}, []string{"plan_id"}},
+		{"BothPlans", SubscribeRequest{PlanID: planId, PlanCode: "BASIC-MONTHLY"}, []string{"plan_code"}},
+		{"EveryField", SubscribeRequest{StartDate: "03/11/2025"}, []string{"plan_id", "start_date"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fields []string
+			if err := tc.req.validate(); err != nil {
+				var appErr *apperrors.Error
+				if !errors.As(err, &appErr) {
+					t.Fatalf("Expected a validation error, got %v", err)
+				}
+				for _, field := range appErr.Fields {
+					fields = append(fields, field.Field)
+				}
+			}
+			if !slices.Equal(fields, tc.wantFields) {
+				t.Errorf("Expected invalid fields %v, got %v", tc.wantFields, fields)
+			}
+		})
+	}
+}
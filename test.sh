@@ -21,6 +21,10 @@ export POSTGRES_PASSWORD=postgres
 export POSTGRES_DB=bss
 export POSTGRES_SSLMODE=disable
 
+echo "Migrating and seeding the database..."
+go run ./src/cmd/bss migrate up
+./dev exec -T postgres psql -U postgres -d bss -q -f /seed.sql
+
 # Run the tests (not in short mode to include integration tests)
 go test -count=1 -v ./src/...
 